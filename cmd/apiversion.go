@@ -0,0 +1,155 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+)
+
+// The versions of the RhinoJob API that rhino can translate its jobs to, the preferred one first.
+// rhino works with the v1alpha1 types internally: supporting a new version means adding it here,
+// and translating the jobs to and from it in convertRhinoJob and rhinoJobFromUnstructured.
+var supportedRhinoJobVersions = []string{"v1alpha1"}
+
+// rhinoJobAPI is what the cluster serves of the RhinoJob API
+type rhinoJobAPI struct {
+	// The versions serving rhinojobs, and the one preferred by the cluster
+	served    []string
+	preferred string
+}
+
+// discoverRhinoJobAPI finds the versions of the RhinoJob API served by the cluster
+func discoverRhinoJobAPI(client discovery.DiscoveryInterface) (*rhinoJobAPI, error) {
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+	api := &rhinoJobAPI{}
+	for _, group := range groups.Groups {
+		if group.Name != RhinoJobGVR.Group {
+			continue
+		}
+		for _, groupVersion := range group.Versions {
+			resources, err := client.ServerResourcesForGroupVersion(groupVersion.GroupVersion)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, resource := range resources.APIResources {
+				if resource.Name == RhinoJobGVR.Resource {
+					api.served = append(api.served, groupVersion.Version)
+				}
+			}
+		}
+		api.preferred = group.PreferredVersion.Version
+	}
+	return api, nil
+}
+
+// resource chooses the version of the RhinoJob API to use: the one preferred by the cluster
+// if rhino supports it, or else the first one supported by both. It fails with an error telling
+// which of rhino and the RHINO operator to upgrade when they have no version in common.
+func (api *rhinoJobAPI) resource() (schema.GroupVersionResource, error) {
+	if len(api.served) == 0 {
+		return schema.GroupVersionResource{}, fmt.Errorf("the cluster does not serve %s, %s",
+			RhinoJobGVR.GroupResource(), installOperatorHint)
+	}
+	gvr := RhinoJobGVR
+	if isSupportedRhinoJobVersion(api.preferred) && api.serves(api.preferred) {
+		gvr.Version = api.preferred
+		return gvr, nil
+	}
+	for _, supported := range supportedRhinoJobVersions {
+		if api.serves(supported) {
+			gvr.Version = supported
+			return gvr, nil
+		}
+	}
+
+	served, supported := strings.Join(api.served, ", "), strings.Join(supportedRhinoJobVersions, ", ")
+	if version.CompareKubeAwareVersionStrings(api.newest(), supportedRhinoJobVersions[0]) > 0 {
+		return schema.GroupVersionResource{}, fmt.Errorf("the RHINO operator is too new for this rhino: it serves %s at %s, "+
+			"while rhino supports %s. Please upgrade rhino", RhinoJobGVR.GroupResource(), served, supported)
+	}
+	return schema.GroupVersionResource{}, fmt.Errorf("the RHINO operator is too old for this rhino: it serves %s at %s, "+
+		"while rhino supports %s. Please upgrade the RHINO operator", RhinoJobGVR.GroupResource(), served, supported)
+}
+
+func (api *rhinoJobAPI) serves(version string) bool {
+	for _, served := range api.served {
+		if served == version {
+			return true
+		}
+	}
+	return false
+}
+
+// newest returns the newest served version, GA versions being newer than beta and alpha ones
+func (api *rhinoJobAPI) newest() string {
+	newest := api.served[0]
+	for _, served := range api.served[1:] {
+		if version.CompareKubeAwareVersionStrings(served, newest) > 0 {
+			newest = served
+		}
+	}
+	return newest
+}
+
+func isSupportedRhinoJobVersion(version string) bool {
+	for _, supported := range supportedRhinoJobVersions {
+		if supported == version {
+			return true
+		}
+	}
+	return false
+}
+
+// convertRhinoJob translates a RhinoJob in the internal model of rhino to the version of the resource
+func convertRhinoJob(obj *unstructured.Unstructured, gvr schema.GroupVersionResource) (*unstructured.Unstructured, error) {
+	switch gvr.Version {
+	case rhinojob.GroupVersion.Version:
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("rhino cannot translate RhinoJobs to %s", gvr.GroupVersion())
+	}
+}
+
+// rhinoJobFromUnstructured reads a RhinoJob of any supported version into the internal model of rhino
+func rhinoJobFromUnstructured(obj *unstructured.Unstructured) (rhinojob.RhinoJob, error) {
+	var rj rhinojob.RhinoJob
+	gv, err := schema.ParseGroupVersion(obj.GetAPIVersion())
+	if err != nil {
+		return rj, err
+	}
+	switch gv.Version {
+	case rhinojob.GroupVersion.Version:
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &rj)
+	default:
+		err = fmt.Errorf("rhino cannot read RhinoJobs of %s", gv)
+	}
+	return rj, err
+}
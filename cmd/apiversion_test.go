@@ -0,0 +1,79 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"testing"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestDiscovery returns a fake discovery client serving rhinojobs at the versions, the first one being preferred
+func newTestDiscovery(versions ...string) *fakediscovery.FakeDiscovery {
+	clientset := fake.NewSimpleClientset()
+	for _, version := range versions {
+		clientset.Resources = append(clientset.Resources, &metav1.APIResourceList{
+			GroupVersion: RhinoJobGVR.Group + "/" + version,
+			APIResources: []metav1.APIResource{{Name: RhinoJobGVR.Resource, Namespaced: true, Kind: "RhinoJob"}},
+		})
+	}
+	return clientset.Discovery().(*fakediscovery.FakeDiscovery)
+}
+
+func TestDiscoverRhinoJobAPI(t *testing.T) {
+	for _, testcase := range []struct {
+		served   []string
+		version  string
+		expected string
+	}{
+		{[]string{"v1alpha1"}, "v1alpha1", ""},
+		// the preferred version is not supported, but another served one is
+		{[]string{"v1beta1", "v1alpha1"}, "v1alpha1", ""},
+		{nil, "", "the cluster does not serve rhinojobs.openrhino.org, " + installOperatorHint},
+		{[]string{"v1beta1", "v1"}, "", "the RHINO operator is too new for this rhino: it serves rhinojobs.openrhino.org at v1beta1, v1, " +
+			"while rhino supports v1alpha1. Please upgrade rhino"},
+		{[]string{"v0alpha1"}, "", "the RHINO operator is too old for this rhino: it serves rhinojobs.openrhino.org at v0alpha1, " +
+			"while rhino supports v1alpha1. Please upgrade the RHINO operator"},
+	} {
+		api, err := discoverRhinoJobAPI(newTestDiscovery(testcase.served...))
+		assert.Equal(t, nil, err, "test discover %v failed: %s", testcase.served, errorMessage(err))
+		assert.Equal(t, testcase.served, api.served)
+		gvr, err := api.resource()
+		assert.Equal(t, testcase.expected, errorMessage(err), "test discover %v", testcase.served)
+		assert.Equal(t, testcase.version, gvr.Version, "test discover %v", testcase.served)
+	}
+}
+
+func TestConvertRhinoJob(t *testing.T) {
+	obj := newTestRhinoJob("test-convert", rhinojob.Running)
+	converted, err := convertRhinoJob(obj, RhinoJobGVR)
+	assert.Equal(t, nil, err, "test convert failed: %s", errorMessage(err))
+	rj, err := rhinoJobFromUnstructured(converted)
+	assert.Equal(t, nil, err, "test convert failed: %s", errorMessage(err))
+	assert.Equal(t, rhinojob.Running, rj.Status.JobStatus)
+
+	gvr := RhinoJobGVR
+	gvr.Version = "v1"
+	_, err = convertRhinoJob(obj, gvr)
+	assert.Equal(t, "rhino cannot translate RhinoJobs to openrhino.org/v1", errorMessage(err))
+	obj.SetAPIVersion("openrhino.org/v1")
+	_, err = rhinoJobFromUnstructured(obj)
+	assert.Equal(t, "rhino cannot read RhinoJobs of openrhino.org/v1", errorMessage(err))
+}
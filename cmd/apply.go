@@ -0,0 +1,278 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+type ApplyOptions struct {
+	filenames      []string
+	recursive      bool
+	forceConflicts bool
+	KubeOptions
+}
+
+// manifest is a RhinoJob read from a file, with the file it comes from to report errors
+type manifest struct {
+	source string
+	obj    *unstructured.Unstructured
+}
+
+func NewApplyCommand() *cobra.Command {
+	applyOpts := &ApplyOptions{}
+	applyCmd := &cobra.Command{
+		Use:   "apply -f [file|directory]",
+		Short: "Create or update RHINO jobs from YAML manifests",
+		Long:  "\nCreate or update the RHINO jobs described in YAML or JSON manifests, with a server-side apply. All the RHINO jobs are validated against the schema of the RhinoJob CRD before any of them is applied.",
+		Example: `  rhino apply -f hello.yaml
+  rhino apply -f jobs/ --namespace user_space
+  rhino export hello | rhino apply -f -`,
+		Args: cobra.NoArgs,
+		RunE: applyOpts.runApply,
+	}
+
+	applyCmd.Flags().StringSliceVarP(&applyOpts.filenames, "filename", "f", nil, `the files or directories holding the RHINO jobs, "-" to read them from the standard input`)
+	applyCmd.Flags().BoolVarP(&applyOpts.recursive, "recursive", "R", false, "also read the manifests in the subdirectories of the directories given by -f")
+	applyCmd.Flags().BoolVar(&applyOpts.forceConflicts, "force-conflicts", false, "take over the fields of the RHINO jobs managed by other tools, instead of failing")
+	applyCmd.MarkFlagRequired("filename")
+	applyOpts.addKubeFlags(applyCmd.Flags())
+
+	return applyCmd
+}
+
+func (a *ApplyOptions) runApply(cmd *cobra.Command, args []string) error {
+	manifests, err := readManifests(a.filenames, a.recursive, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		return fmt.Errorf("no RHINO jobs found in %s", strings.Join(a.filenames, ", "))
+	}
+	if err := a.complete(cmd); err != nil {
+		return err
+	}
+	dynamicClient, err := a.rhinoJobClient()
+	if err != nil {
+		return err
+	}
+	return a.applyRhinoJobs(context.TODO(), dynamicClient, manifests, cmd.Flags().Changed("namespace"),
+		cmd.OutOrStdout(), cmd.ErrOrStderr())
+}
+
+// readManifests reads the documents of the files, of the files with a YAML or JSON extension
+// in the directories, or of in when the file name is "-"
+func readManifests(filenames []string, recursive bool, in io.Reader) ([]manifest, error) {
+	var manifests []manifest
+	for _, filename := range filenames {
+		if filename == "-" {
+			docs, err := decodeManifests("<stdin>", in)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, docs...)
+			continue
+		}
+		paths, err := manifestFiles(filename, recursive)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			docs, err := decodeManifests(path, file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, docs...)
+		}
+	}
+	return manifests, nil
+}
+
+// manifestFiles returns the file itself, or the YAML and JSON files in a directory, sorted by name
+func manifestFiles(filename string, recursive bool) ([]string, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{filename}, nil
+	}
+	var paths []string
+	err = filepath.WalkDir(filename, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != filename && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
+
+// decodeManifests decodes the YAML documents or JSON objects of a file, skipping the empty ones
+func decodeManifests(source string, r io.Reader) ([]manifest, error) {
+	var manifests []manifest
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return manifests, nil
+			}
+			return nil, fmt.Errorf("failed to decode %s: %v", source, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		gvk := obj.GroupVersionKind()
+		if gvk.Group != RhinoJobGVR.Group || gvk.Kind != "RhinoJob" {
+			return nil, fmt.Errorf("%s: only RhinoJobs can be applied, found %s %q", source, gvk.Kind, obj.GetName())
+		}
+		manifests = append(manifests, manifest{source: source, obj: obj})
+	}
+}
+
+// applyRhinoJobs validates all the RHINO jobs, and then applies them one by one.
+// A failure to apply a job is reported and does not stop the others.
+func (a *ApplyOptions) applyRhinoJobs(ctx context.Context, client dynamic.Interface, manifests []manifest,
+	namespaceChanged bool, out io.Writer, warnings io.Writer) error {
+	gvr := a.rhinoJobResource()
+	// The schema of each version of the RhinoJob API is only read once
+	schemas := map[string]*apiextensionsv1.JSONSchemaProps{}
+	schemaOf := func(version string) *apiextensionsv1.JSONSchemaProps {
+		if _, ok := schemas[version]; !ok {
+			versionGVR := gvr
+			versionGVR.Version = version
+			schemas[version] = a.rhinoJobSchema(client, versionGVR, warnings)
+		}
+		return schemas[version]
+	}
+	objs := make([]*unstructured.Unstructured, len(manifests))
+	var invalid []string
+	for i, m := range manifests {
+		obj, err := a.prepareRhinoJob(m, namespaceChanged, gvr, schemaOf)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", m.source, err))
+			continue
+		}
+		objs[i] = obj
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%s\nno RHINO jobs were applied", strings.Join(invalid, "\n"))
+	}
+
+	failed := 0
+	for _, obj := range objs {
+		result, err := a.applyRhinoJob(ctx, client.Resource(gvr).Namespace(obj.GetNamespace()), obj)
+		if err != nil {
+			fmt.Fprintf(out, "Failed to apply RhinoJob %s: %v\n", obj.GetName(), err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(out, "RhinoJob %s %s\n", obj.GetName(), result)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d RhinoJobs could not be applied", failed, len(objs))
+	}
+	return nil
+}
+
+// prepareRhinoJob fills in the namespace of a RHINO job, validates it against the schema of its version,
+// and translates it to the version served by the cluster
+func (a *ApplyOptions) prepareRhinoJob(m manifest, namespaceChanged bool, gvr schema.GroupVersionResource,
+	schemaOf func(version string) *apiextensionsv1.JSONSchemaProps) (*unstructured.Unstructured, error) {
+	obj := m.obj
+	if obj.GetName() == "" {
+		return nil, fmt.Errorf("a RhinoJob must have a name (metadata.name) to be applied")
+	}
+	switch namespace := obj.GetNamespace(); {
+	case namespace == "":
+		obj.SetNamespace(a.namespace)
+	case namespace != a.namespace && namespaceChanged:
+		return nil, fmt.Errorf("the namespace of RhinoJob %s is %s, which does not match --namespace %s",
+			obj.GetName(), namespace, a.namespace)
+	}
+
+	rj, err := rhinoJobFromUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRhinoJob(obj, schemaOf(obj.GroupVersionKind().Version)); err != nil {
+		return nil, err
+	}
+
+	// The status belongs to the RHINO operator, and is not applied
+	rj.Status.JobStatus = ""
+	applied, err := rhinoJobToUnstructured(&rj)
+	if err != nil {
+		return nil, err
+	}
+	return convertRhinoJob(applied, gvr)
+}
+
+// applyRhinoJob applies a RHINO job, and tells whether it was created, configured or unchanged
+func (a *ApplyOptions) applyRhinoJob(ctx context.Context, rjClient dynamic.ResourceInterface,
+	obj *unstructured.Unstructured) (string, error) {
+	existing, err := rjClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	found := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	applied, err := rjClient.Apply(ctx, obj.GetName(), obj,
+		metav1.ApplyOptions{FieldManager: fieldManager, Force: a.forceConflicts})
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !found:
+		return "created", nil
+	case existing.GetResourceVersion() == applied.GetResourceVersion():
+		return "unchanged", nil
+	default:
+		return "configured", nil
+	}
+}
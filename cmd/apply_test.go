@@ -0,0 +1,202 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+)
+
+// newTestApplyClient returns a fake client whose server-side applies create the jobs,
+// or update them with a new resource version when their spec changes
+func newTestApplyClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	client := newFakeDynamicClient(objects...)
+	client.PrependReactor("patch", "rhinojobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		applied := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(patch.GetPatch(), &applied.Object); err != nil {
+			return true, nil, err
+		}
+		tracker := client.Tracker()
+		existing, err := tracker.Get(RhinoJobGVR, patch.GetNamespace(), patch.GetName())
+		if apierrors.IsNotFound(err) {
+			applied.SetResourceVersion("1")
+			return true, applied, tracker.Create(RhinoJobGVR, applied, patch.GetNamespace())
+		}
+		if err != nil {
+			return true, nil, err
+		}
+		current := existing.(*unstructured.Unstructured)
+		if equality.Semantic.DeepEqual(current.Object["spec"], applied.Object["spec"]) {
+			return true, current, nil
+		}
+		version, _ := strconv.Atoi(current.GetResourceVersion())
+		current.Object["spec"] = applied.Object["spec"]
+		current.SetResourceVersion(strconv.Itoa(version + 1))
+		return true, current, tracker.Update(RhinoJobGVR, current, patch.GetNamespace())
+	})
+	return client
+}
+
+func writeTestManifest(t *testing.T, path string, content string) {
+	assert.Equal(t, nil, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Equal(t, nil, os.WriteFile(path, []byte(content), 0644))
+}
+
+const testJobManifest = `apiVersion: openrhino.org/v1alpha1
+kind: RhinoJob
+metadata:
+  name: %s
+spec:
+  image: foo/%s:v1
+  appExec: ./mpi-func
+  parallelism: %d
+`
+
+func TestReadManifests(t *testing.T) {
+	dir := t.TempDir()
+	writeTestManifest(t, filepath.Join(dir, "b.yaml"), strings.Join([]string{
+		"apiVersion: openrhino.org/v1alpha1\nkind: RhinoJob\nmetadata:\n  name: job-b1\n",
+		"apiVersion: openrhino.org/v1alpha1\nkind: RhinoJob\nmetadata:\n  name: job-b2\n",
+	}, "---\n"))
+	writeTestManifest(t, filepath.Join(dir, "a.json"), `{"apiVersion": "openrhino.org/v1alpha1", "kind": "RhinoJob", "metadata": {"name": "job-a"}}`)
+	writeTestManifest(t, filepath.Join(dir, "README.md"), "not a manifest")
+	writeTestManifest(t, filepath.Join(dir, "sub", "c.yml"), "apiVersion: openrhino.org/v1alpha1\nkind: RhinoJob\nmetadata:\n  name: job-c\n")
+
+	names := func(manifests []manifest) []string {
+		var names []string
+		for _, m := range manifests {
+			names = append(names, m.obj.GetName())
+		}
+		return names
+	}
+	manifests, err := readManifests([]string{dir}, false, nil)
+	assert.Equal(t, nil, err, "test read manifests failed: %s", errorMessage(err))
+	assert.Equal(t, []string{"job-a", "job-b1", "job-b2"}, names(manifests))
+	manifests, err = readManifests([]string{dir, "-"}, true, strings.NewReader("---\napiVersion: openrhino.org/v1alpha1\nkind: RhinoJob\nmetadata:\n  name: job-in\n"))
+	assert.Equal(t, nil, err, "test read manifests failed: %s", errorMessage(err))
+	assert.Equal(t, []string{"job-a", "job-b1", "job-b2", "job-c", "job-in"}, names(manifests))
+	assert.Equal(t, "<stdin>", manifests[4].source)
+
+	_, err = readManifests([]string{"-"}, false, strings.NewReader("apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod\n"))
+	assert.Equal(t, `<stdin>: only RhinoJobs can be applied, found Pod "pod"`, errorMessage(err))
+}
+
+func TestApplyRhinoJobs(t *testing.T) {
+	useTestUserConfig(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jobs.yaml")
+	writeTestManifest(t, path, strings.Join([]string{
+		strings.NewReplacer("%s", "test-apply-a", "%d", "2").Replace(testJobManifest),
+		strings.NewReplacer("%s", "test-apply-b", "%d", "4").Replace(testJobManifest),
+	}, "---\n"))
+	client := newTestApplyClient()
+	applyOpts := &ApplyOptions{KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	apply := func() (string, error) {
+		manifests, err := readManifests([]string{path}, false, nil)
+		assert.Equal(t, nil, err, "test apply failed: %s", errorMessage(err))
+		out := new(bytes.Buffer)
+		err = applyOpts.applyRhinoJobs(context.TODO(), client, manifests, false, out, new(bytes.Buffer))
+		return out.String(), err
+	}
+
+	out, err := apply()
+	assert.Equal(t, nil, err, "test apply failed: %s", errorMessage(err))
+	assert.Equal(t, "RhinoJob test-apply-a created\nRhinoJob test-apply-b created\n", out)
+	obj, err := client.Tracker().Get(RhinoJobGVR, testFuncRunNamespace, "test-apply-b")
+	assert.Equal(t, nil, err, "test apply failed: %s", errorMessage(err))
+	rj, err := rhinoJobFromUnstructured(obj.(*unstructured.Unstructured))
+	assert.Equal(t, nil, err, "test apply failed: %s", errorMessage(err))
+	assert.Equal(t, int32(4), *rj.Spec.Parallelism)
+
+	writeTestManifest(t, path, strings.Join([]string{
+		strings.NewReplacer("%s", "test-apply-a", "%d", "2").Replace(testJobManifest),
+		strings.NewReplacer("%s", "test-apply-b", "%d", "8").Replace(testJobManifest),
+	}, "---\n"))
+	out, err = apply()
+	assert.Equal(t, nil, err, "test apply failed: %s", errorMessage(err))
+	assert.Equal(t, "RhinoJob test-apply-a unchanged\nRhinoJob test-apply-b configured\n", out)
+
+	// nothing is applied when a job is invalid
+	writeTestManifest(t, path, strings.Join([]string{
+		strings.NewReplacer("%s", "test-apply-c", "%d", "2").Replace(testJobManifest),
+		strings.NewReplacer("%s", "test-apply-d", "%d", "0").Replace(testJobManifest),
+	}, "---\n"))
+	out, err = apply()
+	assert.Equal(t, "", out)
+	assert.Contains(t, errorMessage(err), path+": invalid RhinoJob:\n  spec.parallelism: Invalid value: 0")
+	assert.Contains(t, errorMessage(err), "no RHINO jobs were applied")
+	_, err = client.Tracker().Get(RhinoJobGVR, testFuncRunNamespace, "test-apply-c")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestApplyNamespace(t *testing.T) {
+	applyOpts := &ApplyOptions{KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	schemaOf := func(string) *apiextensionsv1.JSONSchemaProps { return nil }
+	obj := newTestRhinoJob("test-apply-ns", "")
+	obj.SetNamespace("")
+	prepared, err := applyOpts.prepareRhinoJob(manifest{source: "job.yaml", obj: obj}, false, RhinoJobGVR, schemaOf)
+	assert.Equal(t, nil, err, "test apply failed: %s", errorMessage(err))
+	assert.Equal(t, testFuncRunNamespace, prepared.GetNamespace())
+
+	obj.SetNamespace("other")
+	_, err = applyOpts.prepareRhinoJob(manifest{source: "job.yaml", obj: obj}, true, RhinoJobGVR, schemaOf)
+	assert.Equal(t, "the namespace of RhinoJob test-apply-ns is other, which does not match --namespace rhino-test", errorMessage(err))
+}
+
+func TestExportRhinoJobs(t *testing.T) {
+	obj := newTestRhinoJob("test-export", rhinojob.Running)
+	unstructured.SetNestedField(obj.Object, "foo/export:v1", "spec", "image")
+	obj.SetUID("0123")
+	obj.SetResourceVersion("42")
+	obj.SetGenerateName("test-")
+	obj.SetAnnotations(map[string]string{lastAppliedAnnotation: "{}"})
+	obj.SetLabels(map[string]string{"team": "hpc"})
+	unstructured.SetNestedSlice(obj.Object, []interface{}{map[string]interface{}{"manager": "rhino"}}, "metadata", "managedFields")
+
+	exportOpts := &ExportOptions{rhinojobNames: []string{"test-export", "test-export"}, output: "yaml",
+		KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	out := new(bytes.Buffer)
+	err := exportOpts.exportRhinoJobs(context.TODO(), newFakeDynamicClient(obj), out)
+	assert.Equal(t, nil, err, "test export failed: %s", errorMessage(err))
+	exported := `apiVersion: openrhino.org/v1alpha1
+kind: RhinoJob
+metadata:
+  labels:
+    team: hpc
+  name: test-export
+  namespace: rhino-test
+spec:
+  image: foo/export:v1
+`
+	assert.Equal(t, exported+"---\n"+exported, out.String())
+}
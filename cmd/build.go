@@ -16,22 +16,33 @@
 package cmd
 
 import (
-	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"path"
 	"regexp"
 	"strings"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-units"
 	"github.com/spf13/cobra"
 )
 
 type BuildOptions struct {
-	image string
-	file  string
+	image        string
+	file         string
+	makeArgs     []string
+	execs        []string
+	builderImage string
+	runtimeImage string
 }
 
+// The labels of the base images describing their ABI. An MPI program built on the builder image
+// only runs on a runtime image with the same C library and MPI implementation.
+var baseImageABILabels = []string{"org.openrhino.libc", "org.openrhino.mpi"}
+
 func NewBuildCommand() *cobra.Command {
 	buildOpts := &BuildOptions{}
 
@@ -40,18 +51,60 @@ func NewBuildCommand() *cobra.Command {
 		Short: "Build MPI function/project",
 		Long:  "\nBuild MPI function/project into a docker image",
 		Example: `  rhino build --image foo/hello:v1.0
-  rhino build -f ./src/config/Makefile -i bar/mpibench:v2.1 -- make -j all arch=Linux`,
+  rhino build -f ./src/config/Makefile -i bar/mpibench:v2.1 -- make -j all arch=Linux
+  rhino build --exec solver,preprocess,bench
+  rhino build --builder-image foo/mpich-builder:v4.1 --runtime-image foo/mpich-run:v4.1
+  rhino build   # in a project directory, using the options in rhino.yaml`,
 		Args: buildOpts.validateArgs,
 		RunE: buildOpts.runBuild,
 	}
 
 	buildCmd.Flags().StringVarP(&buildOpts.image, "image", "i", "", "full image form: [registry]/[namespace]/[name]:[tag]")
 	buildCmd.Flags().StringVarP(&buildOpts.file, "file", "f", "", "relative path of the makefile")
+	buildCmd.Flags().StringSliceVar(&buildOpts.execs, "exec", nil, "the executables built by the makefile, to package into the image. The first one is run by default (default \""+defaultExecName+"\")")
+	buildCmd.Flags().StringVar(&buildOpts.builderImage, "builder-image", "", "the base image to compile the MPI program in (default \""+builderBaseImage+"\")")
+	buildCmd.Flags().StringVar(&buildOpts.runtimeImage, "runtime-image", "", "the base image of the built image, to run the MPI program (default \""+runtimeBaseImage+"\")")
 
 	return buildCmd
 }
 
 func (b *BuildOptions) validateArgs(buildCmd *cobra.Command, args []string) error {
+	// Take the options not given on the command line from the project descriptor
+	project, err := loadProjectConfig(".")
+	if err != nil {
+		return err
+	}
+	if project != nil {
+		if !buildCmd.Flags().Changed("image") {
+			b.image = project.Image
+		}
+		if !buildCmd.Flags().Changed("file") {
+			b.file = project.Build.Makefile
+		}
+		b.makeArgs = project.Build.MakeArgs
+		if !buildCmd.Flags().Changed("exec") {
+			b.execs = projectExecs(project)
+		}
+		if !buildCmd.Flags().Changed("builder-image") {
+			b.builderImage = project.Build.BuilderImage
+		}
+		if !buildCmd.Flags().Changed("runtime-image") {
+			b.runtimeImage = project.Build.RuntimeImage
+		}
+	}
+	if len(b.execs) == 0 {
+		b.execs = []string{defaultExecName}
+	}
+	if !allValidExecNames(b.execs) {
+		return fmt.Errorf("the executables (--exec) must be file names, such as %s", defaultExecName)
+	}
+	if b.builderImage == "" {
+		b.builderImage = builderBaseImage
+	}
+	if b.runtimeImage == "" {
+		b.runtimeImage = runtimeBaseImage
+	}
+
 	if len(b.image) == 0 {
 		return fmt.Errorf("please provide the image name")
 	} else if len(b.image) > 63 {
@@ -69,11 +122,8 @@ func (b *BuildOptions) validateArgs(buildCmd *cobra.Command, args []string) erro
 }
 
 func (b *BuildOptions) runBuild(buildCmd *cobra.Command, args []string) error {
-	var execArgs []string
-	var execCommand string
 	var buildCommand []string = []string{"make"}
 	var makefilePath string
-	var funcName string = "mpi-func" //TODO: Since the funcName is hard coded and used in different files, remove this line and use an external const to avoid inconsistency.
 
 	// check Makefile
 	if len(b.file) == 0 {
@@ -90,6 +140,8 @@ func (b *BuildOptions) runBuild(buildCmd *cobra.Command, args []string) error {
 	// add build args
 	if len(args) > 0 {
 		buildCommand = args
+	} else if len(b.makeArgs) > 0 {
+		buildCommand = append(buildCommand, b.makeArgs...)
 	}
 	fmt.Println("Build command:", buildCommand)
 
@@ -102,46 +154,202 @@ func (b *BuildOptions) runBuild(buildCmd *cobra.Command, args []string) error {
 	}
 	fmt.Println("Build tools found. Start building...")
 
-	execCommand = "docker"
-	execArgs = []string{
-		"build", "-t", b.image,
-		"--rm",
-		"--build-arg", "func_name=" + funcName,
-		"--build-arg", "file=" + makefilePath,
-		"--build-arg", "make_args=" + strings.Join(buildCommand[1:], " "),
-		".",
+	dh, err := NewDockerHelper()
+	if err != nil {
+		return err
 	}
-
-	cmd := exec.Command(execCommand, execArgs...)
-	stdoutPipe, err := cmd.StdoutPipe()
+	if len(b.execs) > 1 {
+		if err := checkDockerfileArgs("it cannot package several executables", "func_names"); err != nil {
+			return err
+		}
+	}
+	if err := b.checkBaseImages(dh, buildCmd.ErrOrStderr()); err != nil {
+		return err
+	}
+	patterns, ignoreFile, err := readIgnorePatterns(".")
 	if err != nil {
 		return err
 	}
-	stderrPipe, err := cmd.StderrPipe()
+	files, size, err := buildContextFiles(".", patterns)
 	if err != nil {
 		return err
 	}
-
-	err = cmd.Start()
+	if ignoreFile == "" {
+		ignoreFile = "the default patterns"
+	}
+	fmt.Printf("Build context: %s in %d files, filtered with %s\n", units.HumanSize(float64(size)), len(files), ignoreFile)
+	buildContext := buildContextTar(".", files)
+	defer buildContext.Close()
+	makeArgs := strings.Join(buildCommand[1:], " ")
+	fmt.Println("Executables:", strings.Join(b.execs, ", "))
+	execNames := strings.Join(b.execs, " ")
+	execPaths := make([]string, len(b.execs))
+	for i, exec := range b.execs {
+		execPaths[i] = path.Join(appDir, exec)
+	}
+	configFile, err := loadDockerConfig()
+	if err != nil {
+		return err
+	}
+	authConfigs, err := buildAuthConfigs(configFile)
 	if err != nil {
 		return err
 	}
+	buildOptions := types.ImageBuildOptions{
+		Tags:        []string{b.image},
+		AuthConfigs: authConfigs,
+		Dockerfile:  "Dockerfile",
+		Labels:      map[string]string{execLabel: execPaths[0], execsLabel: strings.Join(execPaths, ",")},
+		Remove:      true,
+		ForceRemove: true,
+		BuildArgs: map[string]*string{
+			"func_name":     &b.execs[0],
+			"func_names":    &execNames,
+			"file":          &makefilePath,
+			"make_args":     &makeArgs,
+			"builder_image": &b.builderImage,
+			"runtime_image": &b.runtimeImage,
+		},
+	}
+	return dh.buildImage(buildContext, buildOptions, buildCmd.OutOrStdout())
+}
+
+// checkBaseImages checks that the Dockerfile can use other base images than the default ones,
+// and that the builder and runtime images are compatible
+func (b *BuildOptions) checkBaseImages(dh *DockerHelper, warnings io.Writer) error {
+	if b.builderImage == builderBaseImage && b.runtimeImage == runtimeBaseImage {
+		return nil
+	}
+	if err := checkDockerfileArgs("it cannot change its base images", "builder_image", "runtime_image"); err != nil {
+		return err
+	}
 
-	go printPipeOutput(stdoutPipe)
-	go printPipeOutput(stderrPipe)
+	fmt.Printf("Base images: %s to build, %s to run\n", b.builderImage, b.runtimeImage)
+	builderLabels, err := dh.imageLabels(b.builderImage)
+	if err != nil {
+		return err
+	}
+	runtimeLabels, err := dh.imageLabels(b.runtimeImage)
+	if err != nil {
+		return err
+	}
+	return checkBaseImageLabels(b.builderImage, b.runtimeImage, builderLabels, runtimeLabels, warnings)
+}
 
-	err = cmd.Wait()
+// checkDockerfileArgs checks that the Dockerfile of the project declares the build arguments of a feature,
+// as the Dockerfiles of projects created by older versions of rhino may not, explaining what it cannot do otherwise
+func checkDockerfileArgs(consequence string, args ...string) error {
+	dockerfile, err := os.ReadFile("Dockerfile")
 	if err != nil {
 		return err
 	}
+	for _, arg := range args {
+		if !strings.Contains(string(dockerfile), "ARG "+arg) {
+			return fmt.Errorf("the Dockerfile does not use the %s build argument, so %s. "+
+				"Please update it from the one of a project created with rhino create", arg, consequence)
+		}
+	}
 	return nil
 }
 
-func printPipeOutput(pipe io.ReadCloser) {
-	scanner := bufio.NewScanner(pipe)
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		output := scanner.Text()
-		fmt.Println(output)
+// checkBaseImageLabels compares the ABI labels of the builder and runtime images.
+// A label missing on either image cannot be checked, and is only reported with a warning.
+func checkBaseImageLabels(builderImage, runtimeImage string, builderLabels, runtimeLabels map[string]string, warnings io.Writer) error {
+	var mismatches []string
+	for _, label := range baseImageABILabels {
+		builderValue, builderFound := builderLabels[label]
+		runtimeValue, runtimeFound := runtimeLabels[label]
+		switch {
+		case !builderFound || !runtimeFound:
+			image := builderImage
+			if builderFound {
+				image = runtimeImage
+			}
+			fmt.Fprintf(warnings, "Warning: cannot check that the base images are compatible: %s has no %s label\n", image, label)
+		case builderValue != runtimeValue:
+			mismatches = append(mismatches, fmt.Sprintf("  %s: %s on %s, %s on %s", label, builderValue, builderImage, runtimeValue, runtimeImage))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("the builder image %s and the runtime image %s are not compatible, "+
+			"the MPI program would not run:\n%s", builderImage, runtimeImage, strings.Join(mismatches, "\n"))
+	}
+	return nil
+}
+
+// BuildError is returned when the Docker daemon fails to build the image, with the step that failed
+type BuildError struct {
+	// Step is the step of the Dockerfile being run, such as "Step 5/12 : RUN sh ldd.sh", if any
+	Step    string
+	Message string
+}
+
+func (e *BuildError) Error() string {
+	if e.Step == "" {
+		return fmt.Sprintf("failed to build the image: %s", e.Message)
+	}
+	return fmt.Sprintf("failed to build the image at %s: %s", e.Step, e.Message)
+}
+
+// buildMessage is a message of the JSON stream returned by the ImageBuild API
+type buildMessage struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	ID          string `json:"id"`
+	Progress    string `json:"progress"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// printBuildOutput prints the output of the build steps from the JSON stream of the ImageBuild API,
+// leaving out the intermediate containers and layers and the pull progress bars,
+// and returns a BuildError if the build fails
+func printBuildOutput(stream io.Reader, out io.Writer) error {
+	decoder := json.NewDecoder(stream)
+	step := ""
+	pending := ""
+	printLine := func(line string) {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "Step "):
+			step = line
+		case strings.HasPrefix(line, " ---> "), strings.HasPrefix(line, "Removing intermediate container "):
+			return
+		}
+		fmt.Fprintln(out, line)
+	}
+	for {
+		var msg buildMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				// The last line of output may not end with a newline
+				if pending != "" {
+					printLine(pending)
+				}
+				return nil
+			}
+			return fmt.Errorf("failed to read the build output: %v", err)
+		}
+		if msg.Error != "" || msg.ErrorDetail != nil {
+			message := msg.Error
+			if msg.ErrorDetail != nil && msg.ErrorDetail.Message != "" {
+				message = msg.ErrorDetail.Message
+			}
+			return &BuildError{Step: step, Message: strings.TrimSpace(message)}
+		}
+		if msg.Status != "" {
+			if msg.Progress == "" {
+				fmt.Fprintln(out, strings.TrimSpace(msg.ID+" "+msg.Status))
+			}
+			continue
+		}
+		// A line of output may be split across several messages
+		lines := strings.Split(pending+msg.Stream, "\n")
+		pending = lines[len(lines)-1]
+		for _, line := range lines[:len(lines)-1] {
+			printLine(line)
+		}
 	}
 }
@@ -16,11 +16,17 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/docker/docker/api/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -82,3 +88,104 @@ func TestBuildSingleFileCpp(t *testing.T) {
 	os.Chdir("..")
 	os.RemoveAll(testFuncName)
 }
+
+// check if the build steps are printed without the noise, and the failing step is reported
+func TestPrintBuildOutput(t *testing.T) {
+	stream := strings.Join([]string{
+		`{"stream":"Step 1/3 : FROM openrhino/mpibuilder_base:v0.1.0 as builder"}`,
+		`{"stream":"\n"}`,
+		`{"status":"Pulling fs layer","id":"a1b2"}`,
+		`{"status":"Downloading","id":"a1b2","progress":"[==>   ] 1MB/4MB","progressDetail":{"current":1,"total":4}}`,
+		`{"stream":" ---\u003e 5d0da3dc9764\n"}`,
+		`{"stream":"Step 2/3 : COPY src/ /app/src\n"}`,
+		`{"stream":" ---\u003e Running in 0f1e2d3c\n"}`,
+		`{"stream":"Removing intermediate container 0f1e2d3c\n"}`,
+		`{"stream":"Step 3/3 : RUN make\n"}`,
+		`{"stream":"hello.cpp:3: error: expected ';'\nmake: *** [Makefile:5: mpi-func] Error 1\n"}`,
+		`{"errorDetail":{"code":2,"message":"The command '/bin/sh -c make' returned a non-zero code: 2"},"error":"The command '/bin/sh -c make' returned a non-zero code: 2"}`,
+	}, "\r\n")
+	out := new(bytes.Buffer)
+	err := printBuildOutput(strings.NewReader(stream), out)
+	assert.Equal(t, `Step 1/3 : FROM openrhino/mpibuilder_base:v0.1.0 as builder
+a1b2 Pulling fs layer
+Step 2/3 : COPY src/ /app/src
+Step 3/3 : RUN make
+hello.cpp:3: error: expected ';'
+make: *** [Makefile:5: mpi-func] Error 1
+`, out.String())
+	var buildErr *BuildError
+	assert.True(t, errors.As(err, &buildErr), "test build output failed: %s", errorMessage(err))
+	assert.Equal(t, "Step 3/3 : RUN make", buildErr.Step)
+	assert.Equal(t, "failed to build the image at Step 3/3 : RUN make: The command '/bin/sh -c make' returned a non-zero code: 2", errorMessage(err))
+
+	out.Reset()
+	err = printBuildOutput(strings.NewReader(`{"stream":"Successfully built 5d0da3dc9764\n"}`), out)
+	assert.Equal(t, nil, err, "test build output failed: %s", errorMessage(err))
+	assert.Equal(t, "Successfully built 5d0da3dc9764\n", out.String())
+
+	out.Reset()
+	err = printBuildOutput(strings.NewReader(`{"stream":"Successfully built 5d0da3dc9764\n"}{"stream":"Successfully tagged "}{"stream":"foo/hello:v1"}`), out)
+	assert.Equal(t, nil, err, "test build output failed: %s", errorMessage(err))
+	assert.Equal(t, "Successfully built 5d0da3dc9764\nSuccessfully tagged foo/hello:v1\n", out.String())
+}
+
+func TestCheckBaseImageLabels(t *testing.T) {
+	builder, runtime := "foo/mpich-builder:v4.1", "foo/mpich-run:v4.1"
+	warnings := new(bytes.Buffer)
+	labels := map[string]string{"org.openrhino.libc": "musl-1.2", "org.openrhino.mpi": "mpich-4"}
+	err := checkBaseImageLabels(builder, runtime, labels, labels, warnings)
+	assert.Equal(t, nil, err, "test base images failed: %s", errorMessage(err))
+	assert.Equal(t, "", warnings.String())
+
+	err = checkBaseImageLabels(builder, runtime, labels, map[string]string{"org.openrhino.mpi": "openmpi-4"}, warnings)
+	assert.Equal(t, "the builder image foo/mpich-builder:v4.1 and the runtime image foo/mpich-run:v4.1 are not compatible, "+
+		"the MPI program would not run:\n  org.openrhino.mpi: mpich-4 on foo/mpich-builder:v4.1, openmpi-4 on foo/mpich-run:v4.1", errorMessage(err))
+	assert.Equal(t, "Warning: cannot check that the base images are compatible: foo/mpich-run:v4.1 has no org.openrhino.libc label\n", warnings.String())
+}
+
+// newTestDockerConfig points $DOCKER_CONFIG to a config file holding credentials for Docker Hub and registry.corp
+func newTestDockerConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	auth := func(user string) string { return base64.StdEncoding.EncodeToString([]byte(user + ":secret")) }
+	data := fmt.Sprintf(`{"auths": {"%s": {"auth": "%s"}, "registry.corp": {"auth": "%s"}}}`,
+		dockerHubAuthKey, auth("hub-user"), auth("corp-user"))
+	err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(data), 0600)
+	assert.Equal(t, nil, err, "test docker config failed: %s", errorMessage(err))
+}
+
+func TestRegistryAuth(t *testing.T) {
+	newTestDockerConfig(t)
+	configFile, err := loadDockerConfig()
+	assert.Equal(t, nil, err, "test registry auth failed: %s", errorMessage(err))
+
+	tests := map[string]string{
+		"registry.corp/mpich-builder:v4":   "corp-user",
+		"openrhino/mpibuilder_base:v0.1.0": "hub-user",
+		"docker.io/library/alpine":         "hub-user",
+		"registry.other:5000/foo:v1":       "",
+	}
+	for image, user := range tests {
+		auth, err := registryAuth(configFile, image)
+		assert.Equal(t, nil, err, "test registry auth of %s failed: %s", image, errorMessage(err))
+		if user == "" {
+			assert.Equal(t, "", auth, "test registry auth of %s failed", image)
+			continue
+		}
+		data, err := base64.URLEncoding.DecodeString(auth)
+		assert.Equal(t, nil, err, "test registry auth of %s failed: %s", image, errorMessage(err))
+		var authConfig types.AuthConfig
+		assert.Equal(t, nil, json.Unmarshal(data, &authConfig))
+		assert.Equal(t, user, authConfig.Username, "test registry auth of %s failed", image)
+		assert.Equal(t, "secret", authConfig.Password, "test registry auth of %s failed", image)
+	}
+
+	authConfigs, err := buildAuthConfigs(configFile)
+	assert.Equal(t, nil, err, "test registry auth failed: %s", errorMessage(err))
+	assert.Equal(t, 2, len(authConfigs))
+	assert.Equal(t, "hub-user", authConfigs[dockerHubAuthKey].Username)
+	assert.Equal(t, "corp-user", authConfigs["registry.corp"].Username)
+
+	_, err = registryAuth(configFile, "Foo/Bar")
+	assert.Contains(t, errorMessage(err), `invalid image name "Foo/Bar"`)
+}
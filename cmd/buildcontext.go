@@ -0,0 +1,178 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// The files listing the patterns of the files left out of the build context, by order of priority.
+// They use the syntax of .dockerignore.
+var ignoreFileNames = []string{".rhinoignore", ".dockerignore"}
+
+// The patterns used when a project has no ignore file: the Dockerfile of the templates only copies these files
+var defaultIgnorePatterns = []string{"*", "!Dockerfile", "!ldd.sh", "!src"}
+
+// The files the build cannot do without, which are sent even when they are ignored
+var requiredContextFiles = []string{"Dockerfile", "ldd.sh"}
+
+// readIgnorePatterns reads the patterns of the ignore file of the project directory, and tells which file it is.
+// The default patterns are returned, with no file name, when there is no ignore file.
+func readIgnorePatterns(dir string) ([]string, string, error) {
+	for _, name := range ignoreFileNames {
+		file, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+		patterns, err := ignorefile.ReadAll(file)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		return patterns, name, nil
+	}
+	return defaultIgnorePatterns, "", nil
+}
+
+// buildContextFiles returns the paths, relative to the directory, of the files and directories
+// not matched by the patterns, and the total size of the files
+func buildContextFiles(dir string, patterns []string) ([]string, int64, error) {
+	pm, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid ignore pattern: %v", err)
+	}
+	var files []string
+	var size int64
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == "." {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if !isRequiredContextFile(name) {
+			ignored, err := pm.MatchesOrParentMatches(name)
+			if err != nil {
+				return err
+			}
+			if ignored {
+				if d.IsDir() && !mayHoldExceptions(pm, name) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		files = append(files, name)
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size, err
+}
+
+func isRequiredContextFile(name string) bool {
+	for _, required := range requiredContextFiles {
+		if name == required {
+			return true
+		}
+	}
+	return false
+}
+
+// mayHoldExceptions tells whether an exception pattern, such as "!data/params.txt", could match
+// a file in an ignored directory, which then cannot be skipped
+func mayHoldExceptions(pm *patternmatcher.PatternMatcher, dir string) bool {
+	for _, pattern := range pm.Patterns() {
+		if pattern.Exclusion() && strings.HasPrefix(pattern.String()+"/", dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// buildContextTar streams the files of the directory as a tar archive, to send as the build context
+func buildContextTar(dir string, files []string) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeContextTar(dir, files, writer))
+	}()
+	return reader
+}
+
+func writeContextTar(dir string, files []string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, name := range files {
+		if err := writeContextFile(tw, filepath.Join(dir, filepath.FromSlash(name)), name); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeContextFile(tw *tar.Writer, path string, name string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	// The owners of the local files mean nothing in the image
+	header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(tw, file)
+	return err
+}
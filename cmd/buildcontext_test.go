@@ -0,0 +1,92 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestProject writes a project directory holding the build files, a source file and data files
+func newTestProject(t *testing.T) string {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"Dockerfile":          "FROM scratch\n",
+		"ldd.sh":              "ldd\n",
+		"rhino.yaml":          "name: hello\n",
+		"src/hello.cpp":       "int main() {}\n",
+		"data/input.bin":      "0123456789",
+		"data/params.txt":     "n=1\n",
+		"output/result-1.txt": "42\n",
+	} {
+		writeTestManifest(t, filepath.Join(dir, name), content)
+	}
+	return dir
+}
+
+func TestBuildContextFiles(t *testing.T) {
+	dir := newTestProject(t)
+	patterns, ignoreFile, err := readIgnorePatterns(dir)
+	assert.Equal(t, nil, err, "test build context failed: %s", errorMessage(err))
+	assert.Equal(t, "", ignoreFile)
+	files, size, err := buildContextFiles(dir, patterns)
+	assert.Equal(t, nil, err, "test build context failed: %s", errorMessage(err))
+	assert.Equal(t, []string{"Dockerfile", "ldd.sh", "src", "src/hello.cpp"}, files)
+	assert.Equal(t, int64(len("FROM scratch\n")+len("ldd\n")+len("int main() {}\n")), size)
+
+	// the .rhinoignore file takes priority over the .dockerignore file, and the build files are always sent
+	writeTestManifest(t, filepath.Join(dir, ".dockerignore"), "*\n")
+	writeTestManifest(t, filepath.Join(dir, ".rhinoignore"), "# outputs\noutput/\ndata\n!data/params.txt\n*.yaml\nDockerfile\n")
+	patterns, ignoreFile, err = readIgnorePatterns(dir)
+	assert.Equal(t, nil, err, "test build context failed: %s", errorMessage(err))
+	assert.Equal(t, ".rhinoignore", ignoreFile)
+	files, _, err = buildContextFiles(dir, patterns)
+	assert.Equal(t, nil, err, "test build context failed: %s", errorMessage(err))
+	assert.Equal(t, []string{".dockerignore", ".rhinoignore", "Dockerfile", "data/params.txt", "ldd.sh", "src", "src/hello.cpp"}, files)
+}
+
+func TestBuildContextTar(t *testing.T) {
+	dir := newTestProject(t)
+	assert.Equal(t, nil, os.Symlink("hello.cpp", filepath.Join(dir, "src", "main.cpp")))
+	files, _, err := buildContextFiles(dir, defaultIgnorePatterns)
+	assert.Equal(t, nil, err, "test build context failed: %s", errorMessage(err))
+
+	buildContext := buildContextTar(dir, files)
+	defer buildContext.Close()
+	contents := map[string]string{}
+	tr := tar.NewReader(buildContext)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Equal(t, nil, err, "test build context failed: %s", errorMessage(err))
+		content, _ := io.ReadAll(tr)
+		contents[header.Name] = string(content) + header.Linkname
+	}
+	assert.Equal(t, map[string]string{
+		"Dockerfile":    "FROM scratch\n",
+		"ldd.sh":        "ldd\n",
+		"src/":          "",
+		"src/hello.cpp": "int main() {}\n",
+		"src/main.cpp":  "hello.cpp",
+	}, contents)
+}
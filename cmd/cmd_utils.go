@@ -16,56 +16,147 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
 )
 
 var RhinoJobGVR = schema.GroupVersionResource{Group: "openrhino.org", Version: "v1alpha1", Resource: "rhinojobs"}
 
-func buildFromKubeconfig(configPath string) (dynamicClient *dynamic.DynamicClient, currentNamespace *string, err error) {
-	// We use 2 kinds of config here.
-	// The dynamicClient need to be constructed with rest.Config.
-	// On the other hand, we need to use api.Config or ClientConfig to
-	// read the context info and current namespace from the kubeconfig file.
-	// The rest.Config does not include the namespace.
-	config, err := clientcmd.BuildConfigFromFlags("", configPath)
+// The base images used by the Dockerfile of the templates, to build and to run MPI programs
+const (
+	builderBaseImage = "openrhino/mpibuilder_base:v0.1.0"
+	runtimeBaseImage = "openrhino/mpirun_base:v0.1.0"
+)
+
+// The images built by rhino build hold the MPI programs in appDir, the working directory of their containers.
+// The program is the executable named defaultExecName by the makefile of the templates, unless the project
+// descriptor names others. The path of the executable run by default is recorded in the execLabel label
+// of the image, and the paths of all its executables in the execsLabel label, separated by commas.
+const (
+	appDir          = "/app"
+	defaultExecName = "mpi-func"
+	execLabel       = "org.openrhino.exec"
+	execsLabel      = "org.openrhino.execs"
+)
+
+// validExecName matches the file names accepted for the executables of a project
+var validExecName = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+// execName returns the name of the executable a project runs by default, which may have no project descriptor
+func execName(project *ProjectConfig) string {
+	switch {
+	case project == nil:
+		return defaultExecName
+	case project.Build.Exec != "":
+		return project.Build.Exec
+	case len(project.Build.Execs) > 0:
+		return project.Build.Execs[0]
+	}
+	return defaultExecName
+}
+
+// projectExecs returns the names of the executables a project packages into its image,
+// starting with the one it runs by default
+func projectExecs(project *ProjectConfig) []string {
+	exec := execName(project)
+	execs := []string{exec}
+	if project != nil {
+		for _, name := range project.Build.Execs {
+			if name != exec {
+				execs = append(execs, name)
+			}
+		}
+	}
+	return execs
+}
+
+// localImageInspectTimeout bounds the lookup of the labels of a local image,
+// so that an unreachable Docker daemon does not hold up the commands that only use it as a hint
+const localImageInspectTimeout = 3 * time.Second
+
+// localImageLabels returns the labels of an image if it is found locally. The image is not pulled,
+// and nil is returned when it cannot be inspected.
+func localImageLabels(image string) map[string]string {
+	dh, err := NewDockerHelper()
 	if err != nil {
-		return nil, nil, err
+		return nil
 	}
-	dynamicClient, err = dynamic.NewForConfig(config)
+	defer dh.cli.Close()
+	ctx, cancel := context.WithTimeout(dh.ctx, localImageInspectTimeout)
+	defer cancel()
+	dh.ctx = ctx
+	labels, err := dh.inspectLabels(image)
 	if err != nil {
-		return nil, nil, err
+		return nil
 	}
+	return labels
+}
 
-	cmdapiConfig, err := clientcmd.LoadFromFile(configPath)
-	if err != nil {
-		return nil, nil, err
+// resolveExec returns the path of the executable to run, the one named name, or else the default one.
+// The executables are taken from the labels of the image, or else from the project with paths
+// starting with dir, when the image has no such labels.
+func resolveExec(labels map[string]string, project *ProjectConfig, name string, dir string) (string, error) {
+	if name != "" && !validExecName.MatchString(name) {
+		return "", fmt.Errorf("invalid executable name (--exec) %q, it must be a file name such as %s", name, defaultExecName)
 	}
-	context, exist := cmdapiConfig.Contexts[cmdapiConfig.CurrentContext]
-	if exist {
-		if context.Namespace == "" {
-			//If namespace is not defined in kubeconfig, use "default"
-			context.Namespace = "default"
+	if defaultExec := labels[execLabel]; defaultExec != "" {
+		if name == "" {
+			return defaultExec, nil
+		}
+		execs := []string{defaultExec}
+		if labels[execsLabel] != "" {
+			execs = strings.Split(labels[execsLabel], ",")
 		}
-		currentNamespace = &context.Namespace
-	} else {
-		return nil, nil, err
+		var names []string
+		for _, exec := range execs {
+			if path.Base(exec) == name {
+				return exec, nil
+			}
+			names = append(names, path.Base(exec))
+		}
+		return "", fmt.Errorf("the image has no executable %s, it has: %s", name, strings.Join(names, ", "))
 	}
 
-	return dynamicClient, currentNamespace, nil
+	if name == "" {
+		return dir + execName(project), nil
+	}
+	// Without a project, trust the name given on the command line
+	if project == nil {
+		return dir + name, nil
+	}
+	execs := projectExecs(project)
+	if containsString(execs, name) {
+		return dir + name, nil
+	}
+	return "", fmt.Errorf("the project has no executable %s, it has: %s. Declare it in build.execs in %s",
+		name, strings.Join(execs, ", "), projectFileName)
 }
 
 func getFuncName(image string) string {
@@ -74,6 +165,87 @@ func getFuncName(image string) string {
 	return funcName
 }
 
+// The operator names the Jobs of a RHINO job after it, adding the longest suffix "-launcher".
+// Job names are used as label values, so they cannot exceed 63 characters.
+const maxRhinoJobNameLength = validation.DNS1123LabelMaxLength - len(launcherJobSuffix)
+
+// The API server appends 5 random characters to the generateName of an object
+const generatedNameSuffixLength = 5
+
+// toDNS1123Label turns a name, such as one taken from an image, into a valid DNS-1123 label
+// no longer than maxLength: lower case alphanumeric characters or '-', starting and ending
+// with an alphanumeric character
+func toDNS1123Label(name string, maxLength int) string {
+	var b strings.Builder
+	lastDash := true
+	for _, c := range strings.ToLower(name) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+			lastDash = false
+		} else if !lastDash {
+			b.WriteByte('-')
+			lastDash = true
+		}
+	}
+	label := b.String()
+	if len(label) > maxLength {
+		label = label[:maxLength]
+	}
+	label = strings.TrimRight(label, "-")
+	if label == "" {
+		label = "rhinojob"
+	}
+	return label
+}
+
+// rhinoJobToUnstructured converts a typed RhinoJob into an unstructured object,
+// leaving out the empty status and creation timestamp of jobs not yet created
+func rhinoJobToUnstructured(rj *rhinojob.RhinoJob) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(rj)
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{Object: content}
+	if rj.Status.JobStatus == "" {
+		unstructured.RemoveNestedField(obj.Object, "status")
+	}
+	if rj.CreationTimestamp.IsZero() {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
+	}
+	return obj, nil
+}
+
+// printObject writes an object to w in the given output format, "yaml" or "json"
+func printObject(w io.Writer, obj interface{}, format string) error {
+	var data []byte
+	var err error
+	switch format {
+	case "yaml":
+		data, err = yaml.Marshal(obj)
+	case "json":
+		data, err = json.MarshalIndent(obj, "", "    ")
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unsupported output format %q, should be one of: yaml, json", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// askConfirmation asks a yes/no question, and tells whether the user answered yes
+func askConfirmation(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
 // DockerHelper is a helper struct for Docker operations
 type DockerHelper struct {
 	ctx context.Context
@@ -94,13 +266,78 @@ func NewDockerHelper() (*DockerHelper, error) {
 	}, nil
 }
 
+// The key of the credentials of Docker Hub in the Docker config file
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// loadDockerConfig loads the Docker config file, $DOCKER_CONFIG/config.json or else ~/.docker/config.json,
+// which holds the credentials of the registries or names the credential helpers storing them
+func loadDockerConfig() (*configfile.ConfigFile, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		dir = config.Dir()
+	}
+	configFile, err := config.Load(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the Docker config file: %v", err)
+	}
+	return configFile, nil
+}
+
+// registryAuth returns the credentials of the registry of an image, encoded as the Docker daemon expects them
+// to pull the image, or an empty string when none are configured
+func registryAuth(configFile *configfile.ConfigFile, image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image name %q: %v", image, err)
+	}
+	key := reference.Domain(named)
+	if key == "docker.io" {
+		key = dockerHubAuthKey
+	}
+	authConfig, err := configFile.GetAuthConfig(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the credentials of %s: %v", key, err)
+	}
+	if authConfig.Username == "" && authConfig.Password == "" && authConfig.Auth == "" &&
+		authConfig.IdentityToken == "" && authConfig.RegistryToken == "" {
+		return "", nil
+	}
+	data, err := json.Marshal(types.AuthConfig(authConfig))
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// buildAuthConfigs returns the credentials of all the registries configured, which are sent with a build,
+// as docker build does, for the Docker daemon to pull the images of FROM
+func buildAuthConfigs(configFile *configfile.ConfigFile) (map[string]types.AuthConfig, error) {
+	credentials, err := configFile.GetAllCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the credentials of the registries: %v", err)
+	}
+	authConfigs := make(map[string]types.AuthConfig, len(credentials))
+	for registry, authConfig := range credentials {
+		authConfigs[registry] = types.AuthConfig(authConfig)
+	}
+	return authConfigs, nil
+}
+
 func (dh *DockerHelper) checkAndPullImage(image string) error {
 	_, _, err := dh.cli.ImageInspectWithRaw(dh.ctx, image)
 	if err != nil {
 		if client.IsErrNotFound(err) {
-			fmt.Printf("Image %s not found, pulling from Docker Hub...\n", image)
+			configFile, err := loadDockerConfig()
+			if err != nil {
+				return err
+			}
+			auth, err := registryAuth(configFile, image)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Image %s not found, pulling it...\n", image)
 			// Pull the image
-			out, err := dh.cli.ImagePull(dh.ctx, image, types.ImagePullOptions{})
+			out, err := dh.cli.ImagePull(dh.ctx, image, types.ImagePullOptions{RegistryAuth: auth})
 			if err != nil {
 				return err
 			}
@@ -117,9 +354,43 @@ func (dh *DockerHelper) checkAndPullImage(image string) error {
 	return nil
 }
 
+// imageLabels returns the labels of the image, pulling it when it is not found locally
+func (dh *DockerHelper) imageLabels(image string) (map[string]string, error) {
+	if err := dh.checkAndPullImage(image); err != nil {
+		return nil, err
+	}
+	return dh.inspectLabels(image)
+}
+
+// inspectLabels returns the labels of a local image
+func (dh *DockerHelper) inspectLabels(image string) (map[string]string, error) {
+	inspect, _, err := dh.cli.ImageInspectWithRaw(dh.ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	if inspect.Config == nil {
+		return nil, nil
+	}
+	return inspect.Config.Labels, nil
+}
+
+// buildImage sends the build context to the Docker daemon to build the image, and prints the build output
+func (dh *DockerHelper) buildImage(buildContext io.Reader, options types.ImageBuildOptions, out io.Writer) error {
+	resp, err := dh.cli.ImageBuild(dh.ctx, buildContext, options)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return printBuildOutput(resp.Body, out)
+}
+
 func (dh *DockerHelper) createAndStartContainer(r *DockerRunOptions, args []string) (string, error) {
 	// Configure the container
-	entrypoint := []string{"mpirun", "-np", strconv.Itoa(r.parallel), "/app/mpi-func"}
+	exec := r.appExec
+	if exec == "" {
+		exec = path.Join(appDir, defaultExecName)
+	}
+	entrypoint := []string{"mpirun", "-np", strconv.Itoa(r.parallel), exec}
 	containerConfig := &container.Config{
 		Image:      args[0],
 		Entrypoint: entrypoint,
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The keys of a profile that can be set with `rhino config set`
+var profileKeys = []string{"kubeconfig", "context", "namespace", "registry", "nfsServer", "ttl"}
+
+type ConfigOptions struct{}
+
+func NewConfigCommand() *cobra.Command {
+	configOpts := &ConfigOptions{}
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the rhino config and its profiles",
+		Long: "\nManage the profiles in ~/.rhino/config.yaml. A profile holds the defaults for the cluster and the registry to use," +
+			"\nand is chosen with the global --profile flag, or else the current profile is used",
+		Example: `  rhino config set namespace user-space
+  rhino config set --profile gpu-cluster kubeconfig /path/to/gpu-cluster.config
+  rhino config use-profile gpu-cluster
+  rhino config view`,
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "view",
+		Short: "Print the rhino config",
+		Args:  cobra.NoArgs,
+		RunE:  configOpts.runView,
+	})
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "set [key] [value]",
+		Short: "Set a key of a profile",
+		Long: "\nSet a key of the profile given by --profile, or else of the current profile." +
+			"\nThe profile is created if it does not exist. Keys: " + strings.Join(profileKeys, ", "),
+		Args: cobra.ExactArgs(2),
+		RunE: configOpts.runSet,
+	})
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "use-profile [name]",
+		Short: "Set the current profile",
+		Args:  cobra.ExactArgs(1),
+		RunE:  configOpts.runUseProfile,
+	})
+
+	return configCmd
+}
+
+func (c *ConfigOptions) runView(cmd *cobra.Command, args []string) error {
+	config, _, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	return printObject(cmd.OutOrStdout(), config, "yaml")
+}
+
+func (c *ConfigOptions) runSet(cmd *cobra.Command, args []string) error {
+	key, value := args[0], args[1]
+	config, path, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+
+	name := profileName(cmd)
+	if name == "" {
+		name = config.CurrentProfile
+	}
+	if name == "" {
+		name = defaultProfileName
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]*Profile{}
+	}
+	profile, exist := config.Profiles[name]
+	if !exist {
+		profile = &Profile{}
+		config.Profiles[name] = profile
+	}
+	if config.CurrentProfile == "" {
+		config.CurrentProfile = name
+	}
+
+	if err := profile.set(key, value); err != nil {
+		return err
+	}
+	if err := config.save(path); err != nil {
+		return err
+	}
+	fmt.Printf("Set %s of profile %s to %q\n", key, name, value)
+	return nil
+}
+
+func (c *ConfigOptions) runUseProfile(cmd *cobra.Command, args []string) error {
+	config, path, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	if _, exist := config.Profiles[args[0]]; !exist {
+		names := make([]string, 0, len(config.Profiles))
+		for name := range config.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("profile %q not found, the profiles are: [%s]", args[0], strings.Join(names, ", "))
+	}
+	config.CurrentProfile = args[0]
+	if err := config.save(path); err != nil {
+		return err
+	}
+	fmt.Println("Switched to profile", args[0])
+	return nil
+}
+
+// set validates and sets a key of the profile. An empty value unsets the key.
+func (p *Profile) set(key string, value string) error {
+	switch key {
+	case "kubeconfig":
+		p.Kubeconfig = value
+	case "context":
+		p.Context = value
+	case "namespace":
+		if errs := validation.IsDNS1123Label(value); value != "" && len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", value, strings.Join(errs, ", "))
+		}
+		p.Namespace = value
+	case "registry":
+		p.Registry = value
+	case "nfsServer":
+		p.NFSServer = value
+	case "ttl":
+		if value == "" {
+			p.TTL = nil
+			return nil
+		}
+		ttl, err := strconv.Atoi(value)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("ttl must be an integer greater than or equal to 0")
+		}
+		p.TTL = &ttl
+	default:
+		return fmt.Errorf("unknown key %q, the keys are: %s", key, strings.Join(profileKeys, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+// useTestUserConfig points the user config to a new file for the rest of the test
+func useTestUserConfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(userConfigEnv, path)
+	return path
+}
+
+func executeRootCommand(t *testing.T, args ...string) (string, error) {
+	rootCmd := NewRootCommand()
+	out := new(bytes.Buffer)
+	rootCmd.SetOut(out)
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestConfigSetAndUseProfile(t *testing.T) {
+	useTestUserConfig(t)
+
+	_, err := executeRootCommand(t, "config", "set", "namespace", "user-space")
+	assert.Equal(t, nil, err, "test config set failed: %s", errorMessage(err))
+	_, err = executeRootCommand(t, "config", "set", "--profile", "gpu", "ttl", "100")
+	assert.Equal(t, nil, err, "test config set failed: %s", errorMessage(err))
+
+	config, _, err := loadUserConfig()
+	assert.Equal(t, nil, err, "test config failed: %s", errorMessage(err))
+	assert.Equal(t, defaultProfileName, config.CurrentProfile)
+	assert.Equal(t, "user-space", config.Profiles[defaultProfileName].Namespace)
+	assert.Equal(t, 100, *config.Profiles["gpu"].TTL)
+
+	_, err = executeRootCommand(t, "config", "use-profile", "gpu")
+	assert.Equal(t, nil, err, "test config use-profile failed: %s", errorMessage(err))
+	out, err := executeRootCommand(t, "config", "view")
+	assert.Equal(t, nil, err, "test config view failed: %s", errorMessage(err))
+	var viewed UserConfig
+	assert.Equal(t, nil, yaml.Unmarshal([]byte(out), &viewed))
+	assert.Equal(t, "gpu", viewed.CurrentProfile)
+
+	_, err = executeRootCommand(t, "config", "use-profile", "cpu")
+	assert.Equal(t, fmt.Errorf(`profile "cpu" not found, the profiles are: [default, gpu]`), err)
+	_, err = executeRootCommand(t, "config", "set", "--", "ttl", "-1")
+	assert.Equal(t, fmt.Errorf("ttl must be an integer greater than or equal to 0"), err)
+	_, err = executeRootCommand(t, "config", "set", "image", "foo")
+	assert.Equal(t, fmt.Errorf(`unknown key "image", the keys are: kubeconfig, context, namespace, registry, nfsServer, ttl`), err)
+}
+
+// check if run takes its defaults from the profile chosen with --profile, or else the current profile
+func TestRunWithProfile(t *testing.T) {
+	useTestUserConfig(t)
+	chdirTemp(t)
+	kubeconfig := writeTestKubeconfig(t, testFuncRunNamespace)
+	for _, args := range [][]string{
+		{"kubeconfig", kubeconfig},
+		{"namespace", "profile-space"},
+		{"ttl", "100"},
+		{"nfsServer", "10.0.0.7"},
+		{"--profile", "other", "namespace", "other-space"},
+	} {
+		_, err := executeRootCommand(t, append([]string{"config", "set"}, args...)...)
+		assert.Equal(t, nil, err, "test config set failed: %s", errorMessage(err))
+	}
+
+	runDryRun := func(args ...string) rhinojob.RhinoJob {
+		out, err := executeRootCommand(t, append([]string{"run", "foo/test-profile:v1", "--dry-run"}, args...)...)
+		assert.Equal(t, nil, err, "test run with profile failed: %s", errorMessage(err))
+		var rj rhinojob.RhinoJob
+		assert.Equal(t, nil, yaml.Unmarshal([]byte(out), &rj))
+		return rj
+	}
+
+	rj := runDryRun()
+	assert.Equal(t, "profile-space", rj.Namespace)
+	assert.Equal(t, int32(100), *rj.Spec.TTL)
+	assert.Equal(t, "", rj.Spec.DataServer, "the NFS server of the profile should only be used with --dir")
+
+	rj = runDryRun("--dir", "/mnt", "--ttl", "50", "-n", "flag-space")
+	assert.Equal(t, "flag-space", rj.Namespace)
+	assert.Equal(t, int32(50), *rj.Spec.TTL)
+	assert.Equal(t, "10.0.0.7", rj.Spec.DataServer)
+	assert.Equal(t, "/mnt", rj.Spec.DataPath)
+
+	rj = runDryRun("--profile", "other", "--kubeconfig", kubeconfig)
+	assert.Equal(t, "other-space", rj.Namespace)
+	assert.Equal(t, int32(600), *rj.Spec.TTL)
+
+	_, err := executeRootCommand(t, "run", "foo/test-profile:v1", "--dry-run", "--profile", "missing")
+	assert.Contains(t, errorMessage(err), `profile "missing" not found`)
+}
+
+// check if create prefixes the image in the project descriptor with the registry of the profile
+func TestCreateWithProfileRegistry(t *testing.T) {
+	useTestUserConfig(t)
+	dir := chdirTemp(t)
+	_, err := executeRootCommand(t, "config", "set", "registry", "registry.example.com/team/")
+	assert.Equal(t, nil, err, "test config set failed: %s", errorMessage(err))
+	_, err = executeRootCommand(t, "create", "test-registry")
+	assert.Equal(t, nil, err, "test create failed: %s", errorMessage(err))
+
+	project, err := loadProjectConfig(filepath.Join(dir, "test-registry"))
+	assert.Equal(t, nil, err, "test create failed: %s", errorMessage(err))
+	assert.Equal(t, "registry.example.com/team/test-registry:latest", project.Image)
+}
+
+// check if the examples of rhino config --help run
+func TestConfigExamples(t *testing.T) {
+	useTestUserConfig(t)
+	for _, example := range strings.Split(NewConfigCommand().Example, "\n") {
+		args := strings.Fields(example)[1:]
+		_, err := executeRootCommand(t, args...)
+		assert.Equal(t, nil, err, "test example %q failed: %s", strings.TrimSpace(example), errorMessage(err))
+	}
+}
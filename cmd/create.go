@@ -62,6 +62,10 @@ func (c *CreateOptions) argsCheck(cmd *cobra.Command, args []string) error {
 
 func (c *CreateOptions) runCreate(cmd *cobra.Command, args []string) error {
 	dirName := args[0]
+	profile, err := loadProfile(cmd)
+	if err != nil {
+		return err
+	}
 	if _, err := os.Stat(dirName); err == nil {
 		return fmt.Errorf("folder %s already exists", dirName)
 	}
@@ -73,6 +77,11 @@ func (c *CreateOptions) runCreate(cmd *cobra.Command, args []string) error {
 	if err := generateTemplate(dirName); err != nil {
 		return fmt.Errorf("generate template failed: %s", err.Error())
 	}
+	project := newProjectConfig(filepath.Base(dirName))
+	project.Image = profile.imageWithRegistry(project.Image)
+	if err := writeProjectConfig(dirName, project); err != nil {
+		return fmt.Errorf("write %s failed: %s", projectFileName, err.Error())
+	}
 
 	return nil
 }
@@ -55,6 +55,14 @@ func TestCreateFunc(t *testing.T) {
 	_, err = os.Stat(testFuncName)
 	assert.Equal(t, nil, err, "test create func failed: %s", errorMessage(err))
 
+	// check if the project descriptor has been generated, and leave it out of the comparison
+	project, err := loadProjectConfig(testFuncName)
+	assert.Equal(t, nil, err, "test create func failed: %s", errorMessage(err))
+	assert.Equal(t, testFuncName, project.Name)
+	assert.Equal(t, testFuncName+":latest", project.Image)
+	err = os.Remove(testFuncName + "/" + projectFileName)
+	assert.Equal(t, nil, err, "test create func failed: %s", errorMessage(err))
+
 	// read the 2 folder and check if they are exactly the same(filename, filecontent)
 	checkGenerateFolerContent(t, testFuncName, templateFuncFolerName)
 
@@ -17,64 +17,331 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"io"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/util/homedir"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	utilwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 )
 
+// How often --wait checks whether a deleted RHINO job and its pods are gone
+const deletePollInterval = time.Second
+
 type DeleteOptions struct {
-	rhinojobName string
-	kubeconfig   string
-	namespace    string
+	rhinojobNames []string
+	all           bool
+	yes           bool
+	wait          bool
+	timeout       time.Duration
+	cascade       string
+
+	FilterOptions
+	KubeOptions
+}
+
+// deleteTarget is a RHINO job to delete
+type deleteTarget struct {
+	namespace string
+	name      string
 }
 
 func NewDeleteCommand() *cobra.Command {
 	deleteOpts := &DeleteOptions{}
 	deleteCmd := &cobra.Command{
-		Use:   "delete",
-		Short: "Delete a RHINO job by name",
-		Long:  "\nDelete a RHINO job by name",
-		Args:  deleteOpts.argsCheck,
-		RunE:  deleteOpts.runDelete,
+		Use:   "delete [name]...",
+		Short: "Delete RHINO jobs by name or by filters",
+		Long:  "\nDelete RHINO jobs by name, all the RHINO jobs with --all, or the RHINO jobs selected by the label selector, field selector, status or image filters",
+		Example: `  rhino delete hello
+  rhino delete job1 job2 job3 --yes
+  rhino delete --all --wait
+  rhino delete --status Failed
+  rhino delete -A -l team=hpc --image 'foo/*' --cascade=foreground`,
+		Args: deleteOpts.argsCheck,
+		RunE: deleteOpts.runDelete,
 	}
-	deleteCmd.Flags().StringVarP(&deleteOpts.namespace, "namespace", "n", "", "namespace of the RHINO job")
-	deleteCmd.Flags().StringVar(&deleteOpts.kubeconfig, "kubeconfig", "", "path to the kubeconfig file")
+	deleteOpts.addKubeFlags(deleteCmd.Flags())
+	deleteOpts.addFilterFlags(deleteCmd.Flags())
+	deleteCmd.Flags().BoolVar(&deleteOpts.all, "all", false, "delete all the RHINO jobs in the namespace, or in all namespaces with -A")
+	deleteCmd.Flags().BoolVarP(&deleteOpts.yes, "yes", "y", false, "do not ask for confirmation before deleting several RHINO jobs")
+	deleteCmd.Flags().BoolVar(&deleteOpts.wait, "wait", false, "wait until the RHINO jobs and their pods are gone")
+	deleteCmd.Flags().DurationVar(&deleteOpts.timeout, "timeout", 0, "the maximum time to wait with --wait, e.g. 30s or 10m. 0 means no timeout")
+	deleteCmd.Flags().StringVar(&deleteOpts.cascade, "cascade", "background", `how the Jobs and pods of the RHINO jobs are deleted: "background", "foreground" or "orphan"`)
 
 	return deleteCmd
 }
 
 func (d *DeleteOptions) argsCheck(cmd *cobra.Command, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("[name] cannot be empty")
+	if err := d.validate(); err != nil {
+		return err
 	}
-	d.rhinojobName = args[0]
-	if len(d.kubeconfig) == 0 {
-		if home := homedir.HomeDir(); home != "" {
-			d.kubeconfig = filepath.Join(home, ".kube", "config")
-		} else {
-			return fmt.Errorf("kubeconfig file not found, please use --config to specify the absolute path")
+	if len(args) == 0 {
+		if !d.all && !d.isSet() {
+			return fmt.Errorf("[name] cannot be empty, unless the RHINO jobs are selected by --all, -l, --field-selector, --status or --image")
 		}
+	} else if d.all || d.isSet() || d.allNamespaces {
+		return fmt.Errorf("RHINO jobs cannot be deleted by [name] and by --all or filters at the same time")
+	}
+	d.rhinojobNames = args
+	if _, err := d.propagationPolicy(); err != nil {
+		return err
+	}
+	if d.timeout < 0 {
+		return fmt.Errorf("the timeout (--timeout) must be greater than or equal to 0")
 	}
 
 	return nil
 }
 
+// propagationPolicy turns --cascade into the deletion propagation policy
+func (d *DeleteOptions) propagationPolicy() (metav1.DeletionPropagation, error) {
+	switch d.cascade {
+	case "background":
+		return metav1.DeletePropagationBackground, nil
+	case "foreground":
+		return metav1.DeletePropagationForeground, nil
+	case "orphan":
+		return metav1.DeletePropagationOrphan, nil
+	}
+	return "", fmt.Errorf(`the cascade mode (--cascade) must be "background", "foreground" or "orphan"`)
+}
+
 func (d *DeleteOptions) runDelete(cmd *cobra.Command, args []string) error {
-	dynamicClient, currentNamespace, err := buildFromKubeconfig(d.kubeconfig)
+	if err := d.complete(cmd); err != nil {
+		return err
+	}
+	dynamicClient, err := d.rhinoJobClient()
+	if err != nil {
+		return err
+	}
+	var clientset kubernetes.Interface
+	if d.wait {
+		if clientset, err = d.clientset(); err != nil {
+			return err
+		}
+	}
+
+	return d.deleteRhinoJobs(context.TODO(), dynamicClient, clientset, cmd.InOrStdin(), cmd.OutOrStdout())
+}
+
+// deleteRhinoJobs deletes the RHINO jobs named on the command line, or else the RHINO jobs selected
+// by --all or the filters, after asking for confirmation when several jobs may be deleted.
+// A failure is reported for each job and does not stop the deletion of the others.
+// With --wait, all the jobs are deleted first, and then waited for together within --timeout.
+func (d *DeleteOptions) deleteRhinoJobs(ctx context.Context, dynamicClient dynamic.Interface,
+	clientset kubernetes.Interface, in io.Reader, out io.Writer) error {
+	targets, err := d.deleteTargets(dynamicClient)
 	if err != nil {
 		return err
 	}
-	if d.namespace == "" {
-		d.namespace = *currentNamespace
+	if len(targets) == 0 {
+		fmt.Fprintln(out, "No RhinoJobs found to delete")
+		return nil
+	}
+	if (len(targets) > 1 || len(d.rhinojobNames) == 0) && !d.yes {
+		confirmed, err := d.confirm(in, out, targets)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Fprintln(out, "Deletion cancelled")
+			return nil
+		}
 	}
 
-	err = dynamicClient.Resource(RhinoJobGVR).Namespace(d.namespace).Delete(context.TODO(), d.rhinojobName, metav1.DeleteOptions{})
+	policy, err := d.propagationPolicy()
 	if err != nil {
 		return err
 	}
-	fmt.Println("RhinoJob " + d.rhinojobName + " deleted")
+	var lastErr error
+	failed := 0
+	var deleted []deleteTarget
+	for _, target := range targets {
+		if err := d.deleteRhinoJob(ctx, dynamicClient, target, policy); err != nil {
+			fmt.Fprintf(out, "Failed to delete RhinoJob %s: %v\n", d.displayName(target), err)
+			lastErr = err
+			failed++
+			continue
+		}
+		if d.wait {
+			deleted = append(deleted, target)
+		} else {
+			fmt.Fprintln(out, "RhinoJob", d.displayName(target), "deleted")
+		}
+	}
+	if d.wait && len(deleted) > 0 {
+		waitCtx, cancel := contextWithTimeout(d.timeout)
+		defer cancel()
+		errs := d.waitForRhinoJobsGone(waitCtx, dynamicClient, clientset, deleted, policy)
+		for i, target := range deleted {
+			if errs[i] != nil {
+				fmt.Fprintf(out, "Failed to delete RhinoJob %s: %v\n", d.displayName(target), errs[i])
+				lastErr = errs[i]
+				failed++
+				continue
+			}
+			fmt.Fprintln(out, "RhinoJob", d.displayName(target), "deleted and gone")
+		}
+	}
+	if failed == 1 && len(targets) == 1 {
+		return lastErr
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d RhinoJobs could not be deleted", failed, len(targets))
+	}
 	return nil
 }
+
+// deleteTargets returns the RHINO jobs named on the command line, or else the RHINO jobs selected by --all or the filters
+func (d *DeleteOptions) deleteTargets(dynamicClient dynamic.Interface) ([]deleteTarget, error) {
+	var targets []deleteTarget
+	if len(d.rhinojobNames) > 0 {
+		for _, name := range d.rhinojobNames {
+			targets = append(targets, deleteTarget{namespace: d.namespace, name: name})
+		}
+		return targets, nil
+	}
+	list, err := d.listRhinoJobs(dynamicClient, d.rhinoJobResource(), d.namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, rj := range list.Items {
+		targets = append(targets, deleteTarget{namespace: rj.Namespace, name: rj.Name})
+	}
+	return targets, nil
+}
+
+// confirm lists the RHINO jobs to delete and asks the user to confirm
+func (d *DeleteOptions) confirm(in io.Reader, out io.Writer, targets []deleteTarget) (bool, error) {
+	fmt.Fprintln(out, "The following RhinoJobs will be deleted:")
+	for _, target := range targets {
+		fmt.Fprintln(out, "  "+d.displayName(target))
+	}
+	return askConfirmation(in, out, fmt.Sprintf("Delete %d RhinoJobs?", len(targets)))
+}
+
+// displayName names a RHINO job, with its namespace when jobs are deleted in all namespaces
+func (d *DeleteOptions) displayName(target deleteTarget) string {
+	if d.allNamespaces {
+		return target.namespace + "/" + target.name
+	}
+	return target.name
+}
+
+// deleteRhinoJob deletes a RHINO job
+func (d *DeleteOptions) deleteRhinoJob(ctx context.Context, dynamicClient dynamic.Interface,
+	target deleteTarget, policy metav1.DeletionPropagation) error {
+	rjClient := dynamicClient.Resource(d.rhinoJobResource()).Namespace(target.namespace)
+	return rjClient.Delete(ctx, target.name, metav1.DeleteOptions{PropagationPolicy: &policy})
+}
+
+// waitForRhinoJobsGone waits until the deleted RHINO jobs are gone, together with their pods unless they are orphaned,
+// and returns the error of each job, nil when it is gone. Every poll lists the RHINO jobs, and the Jobs and pods
+// when needed, once per namespace for all the jobs still waited for, so that waiting for many jobs stays cheap.
+func (d *DeleteOptions) waitForRhinoJobsGone(ctx context.Context, dynamicClient dynamic.Interface,
+	clientset kubernetes.Interface, targets []deleteTarget, policy metav1.DeletionPropagation) []error {
+	pending := make(map[int]bool, len(targets))
+	for i := range targets {
+		pending[i] = true
+	}
+	err := utilwait.PollImmediateUntilWithContext(ctx, deletePollInterval, func(ctx context.Context) (bool, error) {
+		byNamespace := make(map[string][]int)
+		for i := range pending {
+			byNamespace[targets[i].namespace] = append(byNamespace[targets[i].namespace], i)
+		}
+		for namespace, indexes := range byNamespace {
+			list, err := dynamicClient.Resource(d.rhinoJobResource()).Namespace(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return false, err
+			}
+			existing := make(map[string]bool, len(list.Items))
+			for _, item := range list.Items {
+				existing[item.GetName()] = true
+			}
+			var gone []int
+			for _, i := range indexes {
+				if !existing[targets[i].name] {
+					gone = append(gone, i)
+				}
+			}
+			if len(gone) == 0 {
+				continue
+			}
+			var withPods map[string]bool
+			if policy != metav1.DeletePropagationOrphan {
+				if withPods, err = rhinoJobsWithPods(ctx, clientset, namespace); err != nil {
+					return false, err
+				}
+			}
+			for _, i := range gone {
+				if !withPods[targets[i].name] {
+					delete(pending, i)
+				}
+			}
+		}
+		return len(pending) == 0, nil
+	})
+
+	errs := make([]error, len(targets))
+	for i := range pending {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			errs[i] = fmt.Errorf("timed out waiting for RhinoJob %s to be gone", targets[i].name)
+		case ctx.Err() != nil:
+			errs[i] = ctx.Err()
+		default:
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// rhinoJobsWithPods returns the names of the RHINO jobs of a namespace which still have pods,
+// found from the owners of the Jobs of the pods, or else from the names the operator gives to the Jobs
+func rhinoJobsWithPods(ctx context.Context, clientset kubernetes.Interface, namespace string) (map[string]bool, error) {
+	jobList, err := clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]string)
+	for _, job := range jobList.Items {
+		for _, owner := range job.OwnerReferences {
+			if owner.Kind == "RhinoJob" {
+				owners[job.Name] = owner.Name
+				break
+			}
+		}
+	}
+
+	requirement, err := labels.NewRequirement(jobNameLabel, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+	podList, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.NewSelector().Add(*requirement).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	withPods := make(map[string]bool)
+	for _, pod := range podList.Items {
+		jobName := pod.Labels[jobNameLabel]
+		if name, ok := owners[jobName]; ok {
+			withPods[name] = true
+			continue
+		}
+		for _, suffix := range []string{launcherJobSuffix, workersJobSuffix} {
+			if strings.HasSuffix(jobName, suffix) {
+				withPods[strings.TrimSuffix(jobName, suffix)] = true
+			}
+		}
+	}
+	return withPods, nil
+}
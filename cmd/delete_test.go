@@ -16,6 +16,8 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -23,6 +25,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestDeleteSingleJob(t *testing.T) {
@@ -43,7 +47,7 @@ func TestDeleteSingleJob(t *testing.T) {
 
 	// test run command
 	execShellCmd("kubectl", []string{"create", "namespace", testFuncRunNamespace})
-	rootCmd.SetArgs([]string{"run", testFuncImageName, "--namespace", testFuncRunNamespace})
+	rootCmd.SetArgs([]string{"run", testFuncImageName, "--name", testFuncName, "--namespace", testFuncRunNamespace})
 	err = rootCmd.Execute()
 	assert.Equal(t, nil, err, "preparatory work run failed: %s", errorMessage(err))
 
@@ -71,3 +75,100 @@ func TestDeleteSingleJob(t *testing.T) {
 	execShellCmd("docker", []string{"rmi", testFuncImageName})
 	execShellCmd("sh", []string{"-c", "docker rmi -f $(docker images | grep none | grep second | awk '{print $3}')"})
 }
+
+func TestDeleteArgsCheck(t *testing.T) {
+	for _, testcase := range []struct {
+		deleteOpts *DeleteOptions
+		args       []string
+		expected   string
+	}{
+		{&DeleteOptions{cascade: "background"}, nil, "[name] cannot be empty, unless the RHINO jobs are selected by --all, -l, --field-selector, --status or --image"},
+		{&DeleteOptions{cascade: "background", FilterOptions: FilterOptions{allNamespaces: true}}, nil, "[name] cannot be empty, unless the RHINO jobs are selected by --all, -l, --field-selector, --status or --image"},
+		{&DeleteOptions{cascade: "background", FilterOptions: FilterOptions{statuses: []string{"Failed"}}}, []string{"hello"},
+			"RHINO jobs cannot be deleted by [name] and by --all or filters at the same time"},
+		{&DeleteOptions{cascade: "background", all: true}, []string{"hello"},
+			"RHINO jobs cannot be deleted by [name] and by --all or filters at the same time"},
+		{&DeleteOptions{cascade: "never"}, []string{"hello"}, `the cascade mode (--cascade) must be "background", "foreground" or "orphan"`},
+		{&DeleteOptions{cascade: "orphan", all: true}, nil, ""},
+	} {
+		assert.Equal(t, testcase.expected, errorMessage(testcase.deleteOpts.argsCheck(nil, testcase.args)))
+	}
+}
+
+func TestDeleteFilteredJobs(t *testing.T) {
+	client := newTestFilterClient()
+	deleteOpts := &DeleteOptions{
+		cascade:       "background",
+		FilterOptions: FilterOptions{statuses: []string{"Failed"}, allNamespaces: true},
+		KubeOptions:   KubeOptions{namespace: testFuncRunNamespace},
+	}
+	assert.Equal(t, nil, deleteOpts.argsCheck(nil, nil))
+
+	// the deletion is cancelled without confirmation
+	out := new(bytes.Buffer)
+	err := deleteOpts.deleteRhinoJobs(context.TODO(), client, nil, strings.NewReader("n\n"), out)
+	assert.Equal(t, nil, err, "test delete failed: %s", errorMessage(err))
+	assert.Contains(t, out.String(), "  other/test-filter-d\n")
+	assert.Contains(t, out.String(), "Delete 3 RhinoJobs? [y/N]: Deletion cancelled")
+	list, err := client.Resource(RhinoJobGVR).List(context.TODO(), metav1.ListOptions{})
+	assert.Equal(t, nil, err, "test delete failed: %s", errorMessage(err))
+	assert.Equal(t, 4, len(list.Items))
+
+	out.Reset()
+	err = deleteOpts.deleteRhinoJobs(context.TODO(), client, nil, strings.NewReader("yes\n"), out)
+	assert.Equal(t, nil, err, "test delete failed: %s", errorMessage(err))
+	assert.Contains(t, out.String(), "RhinoJob rhino-test/test-filter-a deleted\n")
+	list, err = client.Resource(RhinoJobGVR).List(context.TODO(), metav1.ListOptions{})
+	assert.Equal(t, nil, err, "test delete failed: %s", errorMessage(err))
+	assert.Equal(t, 1, len(list.Items))
+	assert.Equal(t, "test-filter-b", list.Items[0].GetName())
+}
+
+// check if a failed deletion is reported without stopping the others
+func TestDeleteSeveralJobs(t *testing.T) {
+	client := newTestFilterClient()
+	deleteOpts := &DeleteOptions{yes: true, cascade: "foreground", KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	assert.Equal(t, nil, deleteOpts.argsCheck(nil, []string{"test-filter-a", "missing", "test-filter-c"}))
+	out := new(bytes.Buffer)
+	err := deleteOpts.deleteRhinoJobs(context.TODO(), client, nil, strings.NewReader(""), out)
+	assert.Equal(t, "1 of 3 RhinoJobs could not be deleted", errorMessage(err))
+	assert.Contains(t, out.String(), "RhinoJob test-filter-a deleted\n")
+	assert.Contains(t, out.String(), `Failed to delete RhinoJob missing: rhinojobs.openrhino.org "missing" not found`)
+	assert.Contains(t, out.String(), "RhinoJob test-filter-c deleted\n")
+}
+
+func TestDeleteWait(t *testing.T) {
+	deleteOpts := &DeleteOptions{wait: true, cascade: "background", KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	assert.Equal(t, nil, deleteOpts.argsCheck(nil, []string{"test-filter-a"}))
+
+	// the pods of the job are gone
+	out := new(bytes.Buffer)
+	err := deleteOpts.deleteRhinoJobs(context.TODO(), newTestFilterClient(), fake.NewSimpleClientset(), nil, out)
+	assert.Equal(t, nil, err, "test delete --wait failed: %s", errorMessage(err))
+	assert.Equal(t, "RhinoJob test-filter-a deleted and gone\n", out.String())
+
+	// the pods of the job are still there
+	deleteOpts.timeout = 100 * time.Millisecond
+	deleteOpts.yes = true
+	err = deleteOpts.deleteRhinoJobs(context.TODO(), newTestFilterClient(), newTestClientset("test-filter-a"), nil, out)
+	assert.Equal(t, "timed out waiting for RhinoJob test-filter-a to be gone", errorMessage(err))
+
+	// a job still waited for does not keep the others from being deleted
+	client := newTestFilterClient()
+	assert.Equal(t, nil, deleteOpts.argsCheck(nil, []string{"test-filter-a", "test-filter-b", "test-filter-c"}))
+	clientset := newTestClientset("test-filter-a")
+	out.Reset()
+	err = deleteOpts.deleteRhinoJobs(context.TODO(), client, clientset, nil, out)
+	assert.Equal(t, "1 of 3 RhinoJobs could not be deleted", errorMessage(err))
+	// the Jobs and pods are listed once per poll for all the jobs, and the timeout only leaves time for one poll
+	lists := map[string]int{}
+	for _, action := range clientset.Actions() {
+		lists[action.GetVerb()+" "+action.GetResource().Resource]++
+	}
+	assert.Equal(t, map[string]int{"list jobs": 1, "list pods": 1}, lists)
+	assert.Equal(t, "Failed to delete RhinoJob test-filter-a: timed out waiting for RhinoJob test-filter-a to be gone\n"+
+		"RhinoJob test-filter-b deleted and gone\nRhinoJob test-filter-c deleted and gone\n", out.String())
+	list, err := client.Resource(RhinoJobGVR).Namespace(testFuncRunNamespace).List(context.TODO(), metav1.ListOptions{})
+	assert.Equal(t, nil, err, "test delete --wait failed: %s", errorMessage(err))
+	assert.Equal(t, 0, len(list.Items))
+}
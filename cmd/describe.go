@@ -0,0 +1,281 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+type DescribeOptions struct {
+	rhinojobName string
+	KubeOptions
+}
+
+func NewDescribeCommand() *cobra.Command {
+	describeOpts := &DescribeOptions{}
+	describeCmd := &cobra.Command{
+		Use:   "describe [name]",
+		Short: "Show the details of a RHINO job",
+		Long:  "\nShow the spec and status of a RHINO job, together with its pods and recent events",
+		Example: `  rhino describe hello
+  rhino describe matmul --namespace user_space`,
+		Args: describeOpts.argsCheck,
+		RunE: describeOpts.runDescribe,
+	}
+
+	describeOpts.addKubeFlags(describeCmd.Flags())
+
+	return describeCmd
+}
+
+func (d *DescribeOptions) argsCheck(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("[name] cannot be empty")
+	}
+	d.rhinojobName = args[0]
+
+	return nil
+}
+
+func (d *DescribeOptions) runDescribe(cmd *cobra.Command, args []string) error {
+	if err := d.complete(cmd); err != nil {
+		return err
+	}
+	dynamicClient, err := d.rhinoJobClient()
+	if err != nil {
+		return err
+	}
+	clientset, err := d.clientset()
+	if err != nil {
+		return err
+	}
+
+	return d.describeRhinoJob(context.TODO(), dynamicClient, clientset, cmd.OutOrStdout(), time.Now())
+}
+
+func (d *DescribeOptions) describeRhinoJob(ctx context.Context, dynamicClient dynamic.Interface,
+	clientset kubernetes.Interface, out io.Writer, now time.Time) error {
+	obj, err := dynamicClient.Resource(d.rhinoJobResource()).Namespace(d.namespace).Get(ctx, d.rhinojobName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	rj, err := rhinoJobFromUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	pods, err := listRhinoJobPods(ctx, clientset, d.namespace, d.rhinojobName)
+	if err != nil {
+		return err
+	}
+	events, err := listRhinoJobEvents(ctx, clientset, d.namespace, d.rhinojobName, pods)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 2, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", rj.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", rj.Namespace)
+	fmt.Fprintf(w, "Labels:\t%s\n", formatMap(rj.Labels))
+	fmt.Fprintf(w, "Created:\t%s (%s ago)\n", rj.CreationTimestamp.Format(time.RFC3339), duration.HumanDuration(now.Sub(rj.CreationTimestamp.Time)))
+	if startTime := podsStartTime(pods); startTime != nil {
+		fmt.Fprintf(w, "Started:\t%s (%s ago)\n", startTime.Format(time.RFC3339), duration.HumanDuration(now.Sub(startTime.Time)))
+	} else {
+		fmt.Fprintf(w, "Started:\t<not started>\n")
+	}
+	fmt.Fprintf(w, "Status:\t%s\n", formatJobStatus(rj.Status.JobStatus))
+	fmt.Fprintf(w, "TTL:\t%s\n", formatTTL(rj, now))
+	fmt.Fprintf(w, "Spec:\n")
+	fmt.Fprintf(w, "  Image:\t%s\n", rj.Spec.Image)
+	fmt.Fprintf(w, "  App Exec:\t%s\n", rj.Spec.AppExec)
+	fmt.Fprintf(w, "  App Args:\t%s\n", formatAppArgs(rj.Spec.AppArgs))
+	fmt.Fprintf(w, "  Parallelism:\t%s\n", formatInt32(rj.Spec.Parallelism))
+	fmt.Fprintf(w, "  Data Server:\t%s\n", formatOptional(rj.Spec.DataServer))
+	fmt.Fprintf(w, "  Data Path:\t%s\n", formatOptional(rj.Spec.DataPath))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Pods:")
+	if len(pods) == 0 {
+		fmt.Fprintln(out, "  <none>")
+	} else {
+		w = tabwriter.NewWriter(out, 2, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  Name\tRole\tPhase\tNode")
+		for _, pod := range pods {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", pod.Name, podDisplayName(pod), pod.Status.Phase, formatOptional(pod.Spec.NodeName))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(out, "Events:")
+	if len(events) == 0 {
+		fmt.Fprintln(out, "  <none>")
+		return nil
+	}
+	w = tabwriter.NewWriter(out, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  Last Seen\tType\tReason\tObject\tMessage")
+	for _, event := range events {
+		lastSeen := "<unknown>"
+		if t := eventTime(event); !t.IsZero() {
+			lastSeen = duration.HumanDuration(now.Sub(t)) + " ago"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s/%s\t%s\n", lastSeen, event.Type, event.Reason,
+			strings.ToLower(event.InvolvedObject.Kind), event.InvolvedObject.Name, strings.TrimSpace(event.Message))
+	}
+	return w.Flush()
+}
+
+// The most recent events shown by rhino describe
+const maxDescribeEvents = 20
+
+// listRhinoJobEvents returns the most recent events of a RHINO job, its Jobs and its pods, oldest first.
+// The events of each object are selected by the API server, instead of listing all the events of the namespace.
+func listRhinoJobEvents(ctx context.Context, clientset kubernetes.Interface, namespace string,
+	rhinojobName string, pods []corev1.Pod) ([]corev1.Event, error) {
+	involved := []corev1.ObjectReference{
+		{Kind: "RhinoJob", Name: rhinojobName},
+		{Kind: "Job", Name: rhinojobName + launcherJobSuffix},
+		{Kind: "Job", Name: rhinojobName + workersJobSuffix},
+	}
+	for _, pod := range pods {
+		involved = append(involved, corev1.ObjectReference{Kind: "Pod", Name: pod.Name})
+	}
+
+	var events []corev1.Event
+	for _, object := range involved {
+		selector := fields.SelectorFromSet(fields.Set{
+			"involvedObject.kind": object.Kind,
+			"involvedObject.name": object.Name,
+		})
+		eventList, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector.String()})
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range eventList.Items {
+			// Keep only the events of the object, should the field selector be ignored
+			if event.InvolvedObject.Kind == object.Kind && event.InvolvedObject.Name == object.Name {
+				events = append(events, event)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	if len(events) > maxDescribeEvents {
+		events = events[len(events)-maxDescribeEvents:]
+	}
+	return events, nil
+}
+
+// eventTime returns the last time an event was seen
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.FirstTimestamp.Time
+	}
+}
+
+// podsStartTime returns the earliest start time of the pods, or nil if none has started
+func podsStartTime(pods []corev1.Pod) *metav1.Time {
+	var startTime *metav1.Time
+	for _, pod := range pods {
+		if pod.Status.StartTime != nil && (startTime == nil || pod.Status.StartTime.Before(startTime)) {
+			startTime = pod.Status.StartTime
+		}
+	}
+	return startTime
+}
+
+// formatTTL shows the TTL of a RHINO job and the time left before the operator deletes it
+func formatTTL(rj rhinojob.RhinoJob, now time.Time) string {
+	if rj.Spec.TTL == nil {
+		return "<unset>"
+	}
+	left, ok := ttlLeft(rj, now)
+	if !ok {
+		return "0s (never deleted)"
+	}
+	if left <= 0 {
+		return fmt.Sprintf("%ds (expired, deleting)", *rj.Spec.TTL)
+	}
+	return fmt.Sprintf("%ds (%s left)", *rj.Spec.TTL, duration.HumanDuration(left))
+}
+
+func formatJobStatus(status rhinojob.JobStatus) string {
+	if status == "" {
+		return "<unknown>"
+	}
+	return string(status)
+}
+
+func formatAppArgs(args []string) string {
+	if len(args) == 0 {
+		return "<none>"
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = strconv.Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func formatInt32(value *int32) string {
+	if value == nil {
+		return "<unset>"
+	}
+	return strconv.Itoa(int(*value))
+}
+
+func formatOptional(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+func formatMap(m map[string]string) string {
+	if len(m) == 0 {
+		return "<none>"
+	}
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDescribeRhinoJob(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	job := newTestRhinoJob("test-describe", "Pending")
+	job.SetCreationTimestamp(metav1.NewTime(now.Add(-2 * time.Minute)))
+	job.Object["spec"] = map[string]interface{}{
+		"image":       "foo/test-describe:v1",
+		"appExec":     "./mpi-func",
+		"appArgs":     []interface{}{"--in", "a b"},
+		"parallelism": int64(2),
+		"ttl":         int64(600),
+		"dataServer":  "10.0.0.7",
+		"dataPath":    "/mnt",
+	}
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "test-describe-event", Namespace: testFuncRunNamespace},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "test-describe-workers-1-abcde"},
+		Type:           "Warning",
+		Reason:         "FailedScheduling",
+		Message:        "0/1 nodes are available: 1 Insufficient cpu.",
+		LastTimestamp:  metav1.NewTime(now.Add(-30 * time.Second)),
+	}
+	otherEvent := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "other-event", Namespace: testFuncRunNamespace},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "other-pod"},
+		Reason:         "Scheduled",
+	}
+
+	describeOpts := &DescribeOptions{rhinojobName: "test-describe", KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	out := new(bytes.Buffer)
+	err := describeOpts.describeRhinoJob(context.TODO(), newFakeDynamicClient(job),
+		newTestClientset("test-describe", event, otherEvent), out, now)
+	assert.Equal(t, nil, err, "test describe failed: %s", errorMessage(err))
+
+	output := out.String()
+	for _, expected := range []string{
+		`Status:\s+Pending`,
+		`TTL:\s+600s \(8m left\)`,
+		`Image:\s+foo/test-describe:v1`,
+		`App Args:\s+"--in" "a b"`,
+		`Parallelism:\s+2`,
+		`Data Server:\s+10.0.0.7`,
+		`test-describe-launcher-abcde\s+launcher\s+Running\s+node-a`,
+		`test-describe-workers-1-abcde\s+worker-1\s+Running\s+node-b`,
+		`30s ago\s+Warning\s+FailedScheduling\s+pod/test-describe-workers-1-abcde\s+0/1 nodes are available`,
+	} {
+		assert.Regexp(t, regexp.MustCompile(expected), output)
+	}
+	assert.NotContains(t, output, "other-pod")
+}
+
+func TestDescribeRhinoJobNotFound(t *testing.T) {
+	describeOpts := &DescribeOptions{rhinojobName: "test-describe", KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	err := describeOpts.describeRhinoJob(context.TODO(), newFakeDynamicClient(),
+		newTestClientset("test-describe"), new(bytes.Buffer), time.Now())
+	assert.NotEqual(t, nil, err)
+}
+
+// check if only the most recent events of the RHINO job are listed, selected by the API server
+func TestListRhinoJobEvents(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	var objects []runtime.Object
+	for i := 0; i < maxDescribeEvents+5; i++ {
+		objects = append(objects, &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: fmt.Sprintf("test-describe-event-%d", i), Namespace: testFuncRunNamespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "RhinoJob", Name: "test-describe"},
+			Reason:         fmt.Sprintf("Reason%d", i),
+			LastTimestamp:  metav1.NewTime(now.Add(time.Duration(i) * time.Second)),
+		})
+	}
+	clientset := newTestClientset("test-describe", objects...)
+	pods, err := listRhinoJobPods(context.TODO(), clientset, testFuncRunNamespace, "test-describe")
+	assert.Equal(t, nil, err, "test describe failed: %s", errorMessage(err))
+	clientset.ClearActions()
+
+	events, err := listRhinoJobEvents(context.TODO(), clientset, testFuncRunNamespace, "test-describe", pods)
+	assert.Equal(t, nil, err, "test describe failed: %s", errorMessage(err))
+	assert.Equal(t, maxDescribeEvents, len(events))
+	assert.Equal(t, "Reason5", events[0].Reason)
+	assert.Equal(t, fmt.Sprintf("Reason%d", maxDescribeEvents+4), events[len(events)-1].Reason)
+
+	var selectors []string
+	for _, action := range clientset.Actions() {
+		selectors = append(selectors, action.(k8stesting.ListAction).GetListRestrictions().Fields.String())
+	}
+	assert.Equal(t, 3+len(pods), len(selectors))
+	assert.Equal(t, "involvedObject.kind=RhinoJob,involvedObject.name=test-describe", selectors[0])
+}
@@ -24,32 +24,57 @@ import (
 type DockerRunOptions struct {
 	parallel int
 	volume   string
+	exec     string
+	appExec  string
 }
 
 func NewDockerRunCommand() *cobra.Command {
 	dockerRunOpts := &DockerRunOptions{}
 	dockerRunCmd := &cobra.Command{
-		Use:   "docker-run [image]",
+		Use:   "docker-run [image] [-- args]",
 		Short: "Run an MPI program using Docker",
 		Long:  "\nSubmit and run an MPI job using Docker",
 		Example: `  rhino docker-run hello:v1.0
   rhino docker-run foo/matmul:v2.1 --np 4 -- arg1 arg2
-  rhino docker-run bar/image:v3.0 -v /path/on/host:/path/in/container --np 8`,
+  rhino docker-run bar/image:v3.0 -v /path/on/host:/path/in/container --np 8
+  rhino docker-run --np 4 -- arg1 arg2   # in a project directory, using the image in rhino.yaml
+  rhino docker-run foo/solver:v1 --exec bench --np 4`,
 		RunE: dockerRunOpts.dockerRun,
 	}
 
 	dockerRunCmd.Flags().StringVarP(&dockerRunOpts.volume, "volume", "v", "", "Bind mount a volume in the format <host-path>:<container-path>")
 	dockerRunCmd.Flags().IntVar(&dockerRunOpts.parallel, "np", 1, "the number of MPI processes")
+	dockerRunCmd.Flags().StringVar(&dockerRunOpts.exec, "exec", "", "the executable to run, for an image holding several ones. By default, the default executable of the image")
 
 	return dockerRunCmd
 }
 
 func (r *DockerRunOptions) dockerRun(cmd *cobra.Command, args []string) error {
-	// Check the arguments
-	if len(args) == 0 {
+	// Check the arguments, taking the defaults from the project descriptor if there is one
+	project, err := loadProjectConfig(".")
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 && (project == nil || project.Image == "") {
 		cmd.Help()
 		return nil
 	}
+	image, appArgs, err := splitImageArgs(cmd.ArgsLenAtDash(), args, project)
+	if err != nil {
+		return err
+	}
+	if project != nil {
+		if !cmd.Flags().Changed("np") && project.DockerRun.NP != nil {
+			r.parallel = *project.DockerRun.NP
+		}
+		if !cmd.Flags().Changed("volume") {
+			r.volume = project.DockerRun.Volume
+		}
+		if len(appArgs) == 0 {
+			appArgs = project.DockerRun.Args
+		}
+	}
+	args = append([]string{image}, appArgs...)
 	if r.parallel < 1 {
 		return fmt.Errorf("the number of MPI processes (--np) must be greater than 0")
 	}
@@ -59,6 +84,7 @@ func (r *DockerRunOptions) dockerRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	defer helper.cli.Close()
 
 	// Check if the image exists and pull it if necessary
 	err = helper.checkAndPullImage(args[0])
@@ -66,6 +92,15 @@ func (r *DockerRunOptions) dockerRun(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// Run the executable recorded in the labels of the image, or else the one of the project
+	labels, err := helper.inspectLabels(args[0])
+	if err != nil {
+		return err
+	}
+	if r.appExec, err = resolveExec(labels, project, r.exec, appDir+"/"); err != nil {
+		return err
+	}
+
 	// Create and start the container
 	containerID, err := helper.createAndStartContainer(r, args)
 	if err != nil {
@@ -0,0 +1,275 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The namespace and the Deployment of the RHINO operator, as installed by its manifests
+const (
+	operatorNamespace      = "rhino-operator-system"
+	operatorDeploymentName = "rhino-operator-controller-manager"
+)
+
+// The hint given when the RHINO operator is missing
+const installOperatorHint = "install the RHINO operator with: rhino install"
+
+// How long each cluster check may take before it is reported as failed
+const doctorTimeout = 10 * time.Second
+
+type DoctorOptions struct {
+	operatorNamespace string
+	KubeOptions
+}
+
+// checkStatus is the outcome of a doctor check
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+	checkSkip checkStatus = "SKIP"
+)
+
+// doctorReport prints the result of each check, and counts the failed ones
+type doctorReport struct {
+	out    io.Writer
+	total  int
+	failed int
+}
+
+func NewDoctorCommand() *cobra.Command {
+	doctorOpts := &DoctorOptions{}
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the environment and the cluster for RHINO",
+		Long:  "\nCheck that Docker, the base images, the kubeconfig, the cluster and the RHINO operator are ready, and give hints to fix what is not",
+		Example: `  rhino doctor
+  rhino doctor --context gpu-cluster
+  rhino doctor --context gpu-cluster --namespace user_space
+  rhino doctor --operator-namespace rhino-system   # after rhino install --operator-namespace rhino-system`,
+		Args: cobra.NoArgs,
+		RunE: doctorOpts.runDoctor,
+	}
+
+	addOperatorNamespaceFlag(doctorCmd.Flags(), &doctorOpts.operatorNamespace)
+	doctorOpts.addKubeFlags(doctorCmd.Flags())
+
+	return doctorCmd
+}
+
+func (d *DoctorOptions) runDoctor(cmd *cobra.Command, args []string) error {
+	report := &doctorReport{out: cmd.OutOrStdout()}
+	ctx := context.TODO()
+	d.checkDocker(ctx, report)
+
+	clientset, err := d.checkKubeconfig(cmd, report)
+	if err != nil {
+		report.skip("no valid kubeconfig", "API server", "RhinoJob CRD", "RHINO operator", "Permissions")
+	} else {
+		d.checkCluster(ctx, clientset, report)
+	}
+	return report.result()
+}
+
+// add prints the result of a check, with a hint to fix it if any
+func (r *doctorReport) add(name string, status checkStatus, message string, hint string) {
+	r.total++
+	if status == checkFail {
+		r.failed++
+	}
+	fmt.Fprintf(r.out, "[%s] %s: %s\n", status, name, message)
+	if hint != "" && status != checkPass {
+		fmt.Fprintf(r.out, "       hint: %s\n", hint)
+	}
+}
+
+// skip reports the checks that cannot run because an earlier one failed
+func (r *doctorReport) skip(reason string, names ...string) {
+	for _, name := range names {
+		r.add(name, checkSkip, reason, "")
+	}
+}
+
+func (r *doctorReport) result() error {
+	if r.failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", r.failed, r.total)
+	}
+	fmt.Fprintln(r.out, "All checks passed")
+	return nil
+}
+
+// checkDocker checks that the Docker daemon is reachable and the base images of the templates are present
+func (d *DoctorOptions) checkDocker(ctx context.Context, report *doctorReport) {
+	dh, err := NewDockerHelper()
+	if err == nil {
+		_, err = dh.cli.Ping(ctx)
+	}
+	if err != nil {
+		report.add("Docker daemon", checkFail, err.Error(), "start Docker, or set $DOCKER_HOST to a reachable Docker daemon")
+		report.skip("Docker daemon not reachable", "Builder base image", "Runtime base image")
+		return
+	}
+	version, err := dh.cli.ServerVersion(ctx)
+	if err != nil {
+		report.add("Docker daemon", checkFail, err.Error(), "check that your user can use the Docker daemon")
+	} else {
+		report.add("Docker daemon", checkPass, "Docker "+version.Version+" reachable", "")
+	}
+
+	for _, baseImage := range []struct{ name, image string }{
+		{"Builder base image", builderBaseImage},
+		{"Runtime base image", runtimeBaseImage},
+	} {
+		name, image := baseImage.name, baseImage.image
+		_, _, err := dh.cli.ImageInspectWithRaw(ctx, image)
+		switch {
+		case err == nil:
+			report.add(name, checkPass, image+" present", "")
+		case client.IsErrNotFound(err):
+			report.add(name, checkWarn, image+" not found locally",
+				"rhino build pulls it from Docker Hub, or pull it in advance: docker pull "+image)
+		default:
+			report.add(name, checkFail, err.Error(), "")
+		}
+	}
+}
+
+// checkKubeconfig checks that the kubeconfig and its context are valid, and builds the clientset to check the cluster
+func (d *DoctorOptions) checkKubeconfig(cmd *cobra.Command, report *doctorReport) (kubernetes.Interface, error) {
+	clientset, err := d.doctorClientset(cmd)
+	if err != nil {
+		report.add("Kubeconfig", checkFail, err.Error(),
+			"set $KUBECONFIG or use --kubeconfig and --context, and check the current profile with: rhino config view")
+		return nil, err
+	}
+	contextName := d.overrides.CurrentContext
+	if contextName == "" {
+		rawConfig, _ := d.clientConfig().RawConfig()
+		contextName = rawConfig.CurrentContext
+	}
+	if contextName == "" {
+		contextName = "<in-cluster>"
+	}
+	report.add("Kubeconfig", checkPass, fmt.Sprintf("context %s, namespace %s", contextName, d.namespace), "")
+	return clientset, nil
+}
+
+// doctorClientset builds a clientset whose requests time out, so that an unreachable cluster does not hang the checks
+func (d *DoctorOptions) doctorClientset(cmd *cobra.Command) (kubernetes.Interface, error) {
+	if err := d.complete(cmd); err != nil {
+		return nil, err
+	}
+	config, err := d.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = doctorTimeout
+	return kubernetes.NewForConfig(config)
+}
+
+// checkCluster checks that the API server is reachable, the RhinoJob CRD is served, the RHINO operator
+// is ready and the user may create, list and delete RHINO jobs in the namespace
+func (d *DoctorOptions) checkCluster(ctx context.Context, clientset kubernetes.Interface, report *doctorReport) {
+	version, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		report.add("API server", checkFail, err.Error(), "check that the cluster is running and the API server in the kubeconfig is reachable")
+		report.skip("API server not reachable", "RhinoJob CRD", "RHINO operator", "Permissions")
+		return
+	}
+	report.add("API server", checkPass, "Kubernetes "+version.GitVersion+" reachable", "")
+
+	api, err := discoverRhinoJobAPI(clientset.Discovery())
+	if err != nil {
+		report.add("RhinoJob CRD", checkFail, err.Error(), "")
+	} else if len(api.served) == 0 {
+		report.add("RhinoJob CRD", checkFail, RhinoJobGVR.GroupResource().String()+" not served", installOperatorHint)
+	} else if gvr, err := api.resource(); err != nil {
+		report.add("RhinoJob CRD", checkFail, err.Error(), "")
+	} else {
+		message := RhinoJobGVR.GroupResource().String() + " served at " + strings.Join(api.served, ", ")
+		if len(api.served) > 1 {
+			message += ", using " + gvr.Version
+		}
+		report.add("RhinoJob CRD", checkPass, message, "")
+	}
+
+	namespace := d.operatorNamespace
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, operatorDeploymentName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		report.add("RHINO operator", checkFail, "deployment "+namespace+"/"+operatorDeploymentName+" not found",
+			installOperatorHint+", or give its namespace with --operator-namespace")
+	case apierrors.IsForbidden(err):
+		report.add("RHINO operator", checkWarn, "not allowed to read the deployment "+namespace+"/"+operatorDeploymentName,
+			"ask your cluster admin to check that the RHINO operator is running")
+	case err != nil:
+		report.add("RHINO operator", checkFail, err.Error(), "")
+	default:
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		message := fmt.Sprintf("%d/%d replicas ready", deployment.Status.ReadyReplicas, desired)
+		if deployment.Status.ReadyReplicas < desired || desired == 0 {
+			report.add("RHINO operator", checkFail, message,
+				"check the operator with: kubectl -n "+namespace+" describe deployment "+operatorDeploymentName)
+		} else {
+			report.add("RHINO operator", checkPass, message, "")
+		}
+	}
+
+	var denied []string
+	for _, verb := range []string{"create", "list", "delete"} {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: d.namespace,
+					Verb:      verb,
+					Group:     RhinoJobGVR.Group,
+					Resource:  RhinoJobGVR.Resource,
+				},
+			},
+		}
+		review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			report.add("Permissions", checkFail, err.Error(), "")
+			return
+		}
+		if !review.Status.Allowed {
+			denied = append(denied, verb)
+		}
+	}
+	if len(denied) > 0 {
+		report.add("Permissions", checkFail, fmt.Sprintf("not allowed to %s rhinojobs in namespace %s", strings.Join(denied, ", "), d.namespace),
+			"ask your cluster admin for a Role granting create, list and delete on rhinojobs.openrhino.org")
+	} else {
+		report.add("Permissions", checkPass, "allowed to create, list and delete rhinojobs in namespace "+d.namespace, "")
+	}
+}
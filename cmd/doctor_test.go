@@ -0,0 +1,125 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestDoctorClientset returns a fake cluster running Kubernetes v1.24.10,
+// which only allows the given verbs on RHINO jobs
+func newTestDoctorClientset(allowedVerbs []string, objects ...runtime.Object) *fake.Clientset {
+	clientset := fake.NewSimpleClientset(objects...)
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.24.10"}
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		for _, verb := range allowedVerbs {
+			if review.Spec.ResourceAttributes.Verb == verb {
+				review.Status.Allowed = true
+			}
+		}
+		return true, review, nil
+	})
+	return clientset
+}
+
+func TestDoctorClusterReady(t *testing.T) {
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: operatorDeploymentName, Namespace: operatorNamespace},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+	}
+	clientset := newTestDoctorClientset([]string{"create", "list", "delete"}, deployment)
+	clientset.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "openrhino.org/v1alpha1",
+		APIResources: []metav1.APIResource{{Name: "rhinojobs", Namespaced: true, Kind: "RhinoJob"}},
+	}}
+
+	out := new(bytes.Buffer)
+	report := &doctorReport{out: out}
+	doctorOpts := &DoctorOptions{operatorNamespace: operatorNamespace, KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	doctorOpts.checkCluster(context.TODO(), clientset, report)
+	assert.Equal(t, nil, report.result())
+	assert.Equal(t, `[PASS] API server: Kubernetes v1.24.10 reachable
+[PASS] RhinoJob CRD: rhinojobs.openrhino.org served at v1alpha1
+[PASS] RHINO operator: 1/1 replicas ready
+[PASS] Permissions: allowed to create, list and delete rhinojobs in namespace rhino-test
+All checks passed
+`, out.String())
+}
+
+func TestDoctorOperatorMissing(t *testing.T) {
+	clientset := newTestDoctorClientset([]string{"list"})
+
+	out := new(bytes.Buffer)
+	report := &doctorReport{out: out}
+	doctorOpts := &DoctorOptions{operatorNamespace: operatorNamespace, KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	doctorOpts.checkCluster(context.TODO(), clientset, report)
+	assert.Equal(t, "3 of 4 checks failed", errorMessage(report.result()))
+	assert.Equal(t, `[PASS] API server: Kubernetes v1.24.10 reachable
+[FAIL] RhinoJob CRD: rhinojobs.openrhino.org not served
+       hint: `+installOperatorHint+`
+[FAIL] RHINO operator: deployment rhino-operator-system/rhino-operator-controller-manager not found
+       hint: `+installOperatorHint+`, or give its namespace with --operator-namespace
+[FAIL] Permissions: not allowed to create, delete rhinojobs in namespace rhino-test
+       hint: ask your cluster admin for a Role granting create, list and delete on rhinojobs.openrhino.org
+`, out.String())
+}
+
+// check if the operator is looked up in --operator-namespace, and the permissions in --namespace
+func TestDoctorNamespaces(t *testing.T) {
+	for _, newCmd := range []func() *cobra.Command{NewDoctorCommand, NewInstallCommand, NewUninstallCommand, NewVersionCommand} {
+		cmd := newCmd()
+		assert.Equal(t, operatorNamespace, cmd.Flags().Lookup("operator-namespace").DefValue, "test %s failed", cmd.Name())
+		assert.Equal(t, "namespace of the RHINO job", cmd.Flags().Lookup("namespace").Usage, "test %s failed", cmd.Name())
+	}
+
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: operatorDeploymentName, Namespace: "rhino-system"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+	}
+	clientset := newTestDoctorClientset([]string{"create", "list", "delete"}, deployment)
+	var reviewed []string
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		reviewed = append(reviewed, review.Spec.ResourceAttributes.Namespace)
+		return false, nil, nil
+	})
+
+	out := new(bytes.Buffer)
+	report := &doctorReport{out: out}
+	doctorOpts := &DoctorOptions{operatorNamespace: "rhino-system", KubeOptions: KubeOptions{namespace: "user-space"}}
+	doctorOpts.checkCluster(context.TODO(), clientset, report)
+	assert.Contains(t, out.String(), "[PASS] RHINO operator: 1/1 replicas ready\n")
+	assert.Contains(t, out.String(), "[PASS] Permissions: allowed to create, list and delete rhinojobs in namespace user-space\n")
+	assert.Equal(t, []string{"user-space", "user-space", "user-space"}, reviewed)
+}
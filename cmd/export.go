@@ -0,0 +1,104 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+type ExportOptions struct {
+	rhinojobNames []string
+	output        string
+	KubeOptions
+}
+
+// The metadata fields set by the cluster, which are left out of the exported RHINO jobs
+var clusterMetadataFields = []string{"uid", "resourceVersion", "generation", "creationTimestamp",
+	"deletionTimestamp", "deletionGracePeriodSeconds", "managedFields", "selfLink", "ownerReferences", "finalizers", "generateName"}
+
+// The annotation kubectl keeps the last applied configuration in
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+func NewExportCommand() *cobra.Command {
+	exportOpts := &ExportOptions{}
+	exportCmd := &cobra.Command{
+		Use:   "export [name]...",
+		Short: "Print RHINO jobs as manifests to apply again",
+		Long:  "\nPrint existing RHINO jobs as clean manifests, without their status and the metadata set by the cluster, so that they can be kept in version control and applied again with rhino apply",
+		Example: `  rhino export hello > hello.yaml
+  rhino export job1 job2 -o json --namespace user_space`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: exportOpts.runExport,
+	}
+
+	exportCmd.Flags().StringVarP(&exportOpts.output, "output", "o", "yaml", "the output format: yaml or json")
+	exportOpts.addKubeFlags(exportCmd.Flags())
+
+	return exportCmd
+}
+
+func (e *ExportOptions) runExport(cmd *cobra.Command, args []string) error {
+	if e.output != "yaml" && e.output != "json" {
+		return fmt.Errorf("the output format (-o) must be yaml or json")
+	}
+	e.rhinojobNames = args
+	if err := e.complete(cmd); err != nil {
+		return err
+	}
+	dynamicClient, err := e.rhinoJobClient()
+	if err != nil {
+		return err
+	}
+	return e.exportRhinoJobs(context.TODO(), dynamicClient, cmd.OutOrStdout())
+}
+
+// exportRhinoJobs prints the RHINO jobs, as YAML documents separated by "---", or as JSON objects
+func (e *ExportOptions) exportRhinoJobs(ctx context.Context, client dynamic.Interface, out io.Writer) error {
+	for i, name := range e.rhinojobNames {
+		obj, err := client.Resource(e.rhinoJobResource()).Namespace(e.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if i > 0 && e.output == "yaml" {
+			fmt.Fprintln(out, "---")
+		}
+		if err := printObject(out, cleanRhinoJob(obj).Object, e.output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanRhinoJob removes the status and the metadata set by the cluster from a RHINO job
+func cleanRhinoJob(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	clean := obj.DeepCopy()
+	unstructured.RemoveNestedField(clean.Object, "status")
+	for _, field := range clusterMetadataFields {
+		unstructured.RemoveNestedField(clean.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(clean.Object, "metadata", "annotations", lastAppliedAnnotation)
+	if len(clean.GetAnnotations()) == 0 {
+		unstructured.RemoveNestedField(clean.Object, "metadata", "annotations")
+	}
+	return clean
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// FilterOptions holds the options to select the RHINO jobs that list and delete apply to.
+// The label and field selectors are sent to the API server, while the status and image
+// filters are applied to the listed jobs, since the API server cannot select on them.
+type FilterOptions struct {
+	selector      string
+	fieldSelector string
+	allNamespaces bool
+	statuses      []string
+	image         string
+	chunkSize     int64
+}
+
+// addFilterFlags adds the flags to select the RHINO jobs
+func (f *FilterOptions) addFilterFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&f.selector, "selector", "l", "", "label selector to filter the RHINO jobs on, e.g. -l key1=value1,key2!=value2")
+	flags.StringVar(&f.fieldSelector, "field-selector", "", "field selector to filter the RHINO jobs on, e.g. --field-selector metadata.name=hello")
+	flags.BoolVarP(&f.allNamespaces, "all-namespaces", "A", false, "select the RHINO jobs in all namespaces, ignoring --namespace")
+	flags.StringSliceVar(&f.statuses, "status", nil, "only select the RHINO jobs in the given status: Pending, Running, Completed or Failed. Several statuses can be separated by commas")
+	flags.StringVar(&f.image, "image", "", "only select the RHINO jobs whose image matches a pattern, e.g. --image 'foo/*'")
+	flags.Int64Var(&f.chunkSize, "chunk-size", 500, "list the RHINO jobs in chunks of this size, to lower the load on the API server with many RHINO jobs. 0 lists them all at once")
+}
+
+// validate checks the selectors and filters, and normalizes the statuses
+func (f *FilterOptions) validate() error {
+	if _, err := labels.Parse(f.selector); err != nil {
+		return fmt.Errorf("invalid label selector (-l) %q: %v", f.selector, err)
+	}
+	if _, err := fields.ParseSelector(f.fieldSelector); err != nil {
+		return fmt.Errorf("invalid field selector (--field-selector) %q: %v", f.fieldSelector, err)
+	}
+	for i, status := range f.statuses {
+		found := false
+		for _, jobStatus := range []rhinojob.JobStatus{rhinojob.Pending, rhinojob.Running, rhinojob.Completed, rhinojob.Failed} {
+			if strings.EqualFold(status, string(jobStatus)) {
+				f.statuses[i] = string(jobStatus)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid status (--status) %q, should be one of: Pending, Running, Completed, Failed", status)
+		}
+	}
+	if _, err := path.Match(f.image, ""); err != nil {
+		return fmt.Errorf("invalid image pattern (--image) %q: %v", f.image, err)
+	}
+	if f.chunkSize < 0 {
+		return fmt.Errorf("the chunk size (--chunk-size) must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// isSet tells whether any selector or filter narrows down the RHINO jobs
+func (f *FilterOptions) isSet() bool {
+	return f.selector != "" || f.fieldSelector != "" || len(f.statuses) > 0 || f.image != ""
+}
+
+// listNamespace returns the namespace to list the RHINO jobs in, which is all of them with -A
+func (f *FilterOptions) listNamespace(namespace string) string {
+	if f.allNamespaces {
+		return metav1.NamespaceAll
+	}
+	return namespace
+}
+
+// listOptions returns the options selecting the RHINO jobs on the API server
+func (f *FilterOptions) listOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: f.selector, FieldSelector: f.fieldSelector}
+}
+
+// matches tells whether a RHINO job passes the status and image filters
+func (f *FilterOptions) matches(rj rhinojob.RhinoJob) bool {
+	if len(f.statuses) > 0 {
+		found := false
+		for _, status := range f.statuses {
+			if string(rj.Status.JobStatus) == status {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.image != "" {
+		if matched, _ := path.Match(f.image, rj.Spec.Image); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// listRhinoJobs lists the RHINO jobs selected by the filter options in the namespace, at the version of gvr.
+// The jobs are listed in chunks of --chunk-size, and each chunk is converted into typed
+// RHINO jobs and filtered before the next one is fetched, so that only the selected jobs are kept.
+func (f *FilterOptions) listRhinoJobs(client dynamic.Interface, gvr schema.GroupVersionResource,
+	namespace string) (*rhinojob.RhinoJobList, error) {
+	rjClient := client.Resource(gvr).Namespace(f.listNamespace(namespace))
+	listOptions := f.listOptions()
+	listOptions.Limit = f.chunkSize
+	rjList := &rhinojob.RhinoJobList{
+		TypeMeta: metav1.TypeMeta{APIVersion: rhinojob.GroupVersion.String(), Kind: "RhinoJobList"},
+	}
+	for {
+		page, err := rjClient.List(context.TODO(), listOptions)
+		if err != nil {
+			return nil, err
+		}
+		// All the chunks are read from the same version, the one to watch from
+		rjList.ResourceVersion = page.GetResourceVersion()
+		for _, item := range page.Items {
+			rj, err := rhinoJobFromUnstructured(&item)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read RhinoJob %s: %v", item.GetName(), err)
+			}
+			if f.matches(rj) {
+				rjList.Items = append(rjList.Items, rj)
+			}
+		}
+		if page.GetContinue() == "" {
+			return rjList, nil
+		}
+		listOptions.Continue = page.GetContinue()
+	}
+}
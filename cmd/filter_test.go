@@ -0,0 +1,153 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+// newTestFilterClient returns a fake client holding RHINO jobs in two namespaces
+func newTestFilterClient() *fake.FakeDynamicClient {
+	newJob := func(namespace string, name string, status rhinojob.JobStatus, image string, team string) *unstructured.Unstructured {
+		job := newTestRhinoJob(name, status)
+		job.SetNamespace(namespace)
+		job.SetLabels(map[string]string{"team": team})
+		unstructured.SetNestedField(job.Object, image, "spec", "image")
+		return job
+	}
+	return newFakeDynamicClient(
+		newJob(testFuncRunNamespace, "test-filter-a", rhinojob.Failed, "foo/matmul:v2.1", "hpc"),
+		newJob(testFuncRunNamespace, "test-filter-b", rhinojob.Running, "foo/hello:v1", "hpc"),
+		newJob(testFuncRunNamespace, "test-filter-c", rhinojob.Failed, "bar/hello:v1", "ai"),
+		newJob("other", "test-filter-d", rhinojob.Failed, "foo/hello:v1", "hpc"),
+	)
+}
+
+func listedNames(list *rhinojob.RhinoJobList) []string {
+	var names []string
+	for _, rj := range list.Items {
+		names = append(names, rj.Namespace+"/"+rj.Name)
+	}
+	return names
+}
+
+func TestFilterRhinoJobs(t *testing.T) {
+	client := newTestFilterClient()
+	for _, testcase := range []struct {
+		filter   FilterOptions
+		expected []string
+	}{
+		{FilterOptions{}, []string{"rhino-test/test-filter-a", "rhino-test/test-filter-b", "rhino-test/test-filter-c"}},
+		{FilterOptions{statuses: []string{"failed"}}, []string{"rhino-test/test-filter-a", "rhino-test/test-filter-c"}},
+		{FilterOptions{statuses: []string{"Failed"}, image: "foo/*"}, []string{"rhino-test/test-filter-a"}},
+		{FilterOptions{statuses: []string{"Running", "Pending"}}, []string{"rhino-test/test-filter-b"}},
+		{FilterOptions{selector: "team=hpc", allNamespaces: true}, []string{"other/test-filter-d", "rhino-test/test-filter-a", "rhino-test/test-filter-b"}},
+		{FilterOptions{image: "*/hello:*", allNamespaces: true}, []string{"other/test-filter-d", "rhino-test/test-filter-b", "rhino-test/test-filter-c"}},
+	} {
+		assert.Equal(t, nil, testcase.filter.validate())
+		list, err := testcase.filter.listRhinoJobs(client, RhinoJobGVR, testFuncRunNamespace)
+		assert.Equal(t, nil, err, "test filter failed: %s", errorMessage(err))
+		assert.ElementsMatch(t, testcase.expected, listedNames(list), "test filter %+v", testcase.filter)
+	}
+}
+
+func TestFilterInvalid(t *testing.T) {
+	for _, testcase := range []struct {
+		filter   FilterOptions
+		expected string
+	}{
+		{FilterOptions{selector: "team in hpc"}, `invalid label selector (-l) "team in hpc"`},
+		{FilterOptions{fieldSelector: "metadata.name"}, `invalid field selector (--field-selector) "metadata.name"`},
+		{FilterOptions{statuses: []string{"Done"}}, `invalid status (--status) "Done", should be one of: Pending, Running, Completed, Failed`},
+		{FilterOptions{image: "foo/["}, `invalid image pattern (--image) "foo/["`},
+	} {
+		assert.Contains(t, errorMessage(testcase.filter.validate()), testcase.expected)
+	}
+}
+
+// pagedClient serves the RHINO jobs in pages, like an API server honoring Limit and Continue
+type pagedClient struct {
+	dynamic.Interface
+	pages   [][]*unstructured.Unstructured
+	options []metav1.ListOptions
+}
+
+type pagedResource struct {
+	dynamic.NamespaceableResourceInterface
+	client *pagedClient
+}
+
+func (c *pagedClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &pagedResource{client: c}
+}
+
+func (r *pagedResource) Namespace(string) dynamic.ResourceInterface {
+	return r
+}
+
+func (r *pagedResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	r.client.options = append(r.client.options, opts)
+	page := len(r.client.options) - 1
+	list := &unstructured.UnstructuredList{}
+	list.SetResourceVersion("42")
+	if page+1 < len(r.client.pages) {
+		list.SetContinue("page-" + strconv.Itoa(page+1))
+	}
+	for _, item := range r.client.pages[page] {
+		list.Items = append(list.Items, *item)
+	}
+	return list, nil
+}
+
+// check if the jobs are listed page by page, and if a job without parallelism is listed
+func TestListRhinoJobsInChunks(t *testing.T) {
+	withParallelism := newTestRhinoJob("test-chunk-b", rhinojob.Running)
+	unstructured.SetNestedField(withParallelism.Object, int64(4), "spec", "parallelism")
+	client := &pagedClient{pages: [][]*unstructured.Unstructured{
+		{newTestRhinoJob("test-chunk-a", rhinojob.Failed), withParallelism},
+		{newTestRhinoJob("test-chunk-c", rhinojob.Failed)},
+	}}
+	listOpts := &ListOptions{FilterOptions: FilterOptions{chunkSize: 2}, KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	list, err := listOpts.listRhinoJob(client)
+	assert.Equal(t, nil, err, "test list failed: %s", errorMessage(err))
+
+	assert.Equal(t, 2, len(client.options))
+	assert.Equal(t, int64(2), client.options[0].Limit)
+	assert.Equal(t, "", client.options[0].Continue)
+	assert.Equal(t, "page-1", client.options[1].Continue)
+	assert.Equal(t, "42", list.ResourceVersion)
+	assert.Equal(t, []string{"rhino-test/test-chunk-a", "rhino-test/test-chunk-b", "rhino-test/test-chunk-c"}, listedNames(list))
+	assert.Nil(t, list.Items[0].Spec.Parallelism)
+
+	out := new(bytes.Buffer)
+	err = listOpts.printRhinoJobs(out, list, time.Now())
+	assert.Equal(t, nil, err, "test list failed: %s", errorMessage(err))
+	assert.Regexp(t, regexp.MustCompile(`test-chunk-a\s+<unset>\s+Failed`), out.String())
+	assert.Regexp(t, regexp.MustCompile(`test-chunk-b\s+4\s+Running`), out.String())
+}
@@ -0,0 +1,352 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenRHINO/RHINO-CLI/generate"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	utilwait "k8s.io/apimachinery/pkg/util/wait"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+// The field manager of the objects applied by rhino
+const fieldManager = "rhino"
+
+// The resources rhino install waits for
+var (
+	crdGVR        = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	deploymentGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	namespaceGVR  = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+)
+
+// How often rhino install checks whether the RHINO operator is ready
+const installPollInterval = 2 * time.Second
+
+type InstallOptions struct {
+	version           string
+	timeout           time.Duration
+	operatorNamespace string
+	KubeOptions
+}
+
+func NewInstallCommand() *cobra.Command {
+	installOpts := &InstallOptions{}
+	installCmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install the RHINO operator",
+		Long:  "\nInstall the RHINO operator and its CRDs with the manifests embedded in rhino, and wait until the operator is ready. No access to the Internet is needed.",
+		Example: `  rhino install
+  rhino install --version v0.1.0 --operator-namespace rhino-system --timeout 10m`,
+		Args: cobra.NoArgs,
+		RunE: installOpts.runInstall,
+	}
+
+	installCmd.Flags().StringVar(&installOpts.version, "version", "", "the version of the RHINO operator to install. By default, the latest version embedded in rhino")
+	installCmd.Flags().DurationVar(&installOpts.timeout, "timeout", 5*time.Minute, "the maximum time to wait for the RHINO operator to be ready. 0 means no timeout")
+	addOperatorNamespaceFlag(installCmd.Flags(), &installOpts.operatorNamespace)
+	installOpts.addKubeFlags(installCmd.Flags())
+
+	return installCmd
+}
+
+// addOperatorNamespaceFlag adds the --operator-namespace flag, choosing the namespace of the RHINO operator,
+// apart from --namespace which is the namespace of the RHINO jobs
+func addOperatorNamespaceFlag(flags *pflag.FlagSet, namespace *string) {
+	flags.StringVar(namespace, "operator-namespace", operatorNamespace, "the namespace of the RHINO operator")
+}
+
+func (i *InstallOptions) runInstall(cmd *cobra.Command, args []string) error {
+	if i.timeout < 0 {
+		return fmt.Errorf("the timeout (--timeout) must be greater than or equal to 0")
+	}
+	if err := i.complete(cmd); err != nil {
+		return err
+	}
+	namespace := i.operatorNamespace
+	version, objs, err := loadOperatorManifests(i.version, namespace)
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := i.dynamicClient()
+	if err != nil {
+		return err
+	}
+	mapper, err := i.restMapper()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := contextWithTimeout(i.timeout)
+	defer cancel()
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Installing the RHINO operator %s in namespace %s\n", version, namespace)
+	if namespace != operatorNamespace {
+		if err := ensureNamespace(ctx, dynamicClient, namespace, out); err != nil {
+			return err
+		}
+	}
+	if err := applyObjects(ctx, dynamicClient, mapper, objs, out); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Waiting for the RHINO operator to be ready...")
+	if err := waitForOperator(ctx, dynamicClient, objs); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "The RHINO operator %s is ready\n", version)
+	return nil
+}
+
+// operatorVersions returns the versions of the RHINO operator whose manifests are embedded in rhino, oldest first
+func operatorVersions() ([]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(generate.OperatorManifestsZip), int64(len(generate.OperatorManifestsZip)))
+	if err != nil {
+		return nil, err
+	}
+	var versions []*version.Version
+	seen := map[string]bool{}
+	for _, file := range zr.File {
+		dir, _, found := strings.Cut(file.Name, "/")
+		if !found || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		v, err := version.ParseSemantic(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid embedded operator version %q: %v", dir, err)
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].LessThan(versions[j])
+	})
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = "v" + v.String()
+	}
+	return names, nil
+}
+
+// loadOperatorManifests decodes the embedded manifests of a version of the RHINO operator,
+// the latest one if the version is empty, and moves the operator into the namespace.
+// The objects are returned in the order they must be applied.
+func loadOperatorManifests(operatorVersion string, namespace string) (string, []*unstructured.Unstructured, error) {
+	versions, err := operatorVersions()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(versions) == 0 {
+		return "", nil, fmt.Errorf("no RHINO operator manifests are embedded in rhino")
+	}
+	if operatorVersion == "" {
+		operatorVersion = versions[len(versions)-1]
+	}
+	if !strings.HasPrefix(operatorVersion, "v") {
+		operatorVersion = "v" + operatorVersion
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(generate.OperatorManifestsZip), int64(len(generate.OperatorManifestsZip)))
+	if err != nil {
+		return "", nil, err
+	}
+	var files []*zip.File
+	for _, file := range zr.File {
+		if path.Dir(file.Name) == operatorVersion && strings.HasSuffix(file.Name, ".yaml") {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return "", nil, fmt.Errorf("RHINO operator %s is not embedded in rhino, the available versions are: %s",
+			operatorVersion, strings.Join(versions, ", "))
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	var objs []*unstructured.Unstructured
+	for _, file := range files {
+		r, err := file.Open()
+		if err != nil {
+			return "", nil, err
+		}
+		decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+		for {
+			obj := &unstructured.Unstructured{}
+			if err := decoder.Decode(&obj.Object); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				r.Close()
+				return "", nil, fmt.Errorf("failed to decode %s: %v", file.Name, err)
+			}
+			if len(obj.Object) == 0 {
+				continue
+			}
+			// A namespace given by --operator-namespace may hold other workloads, so it is neither labeled
+			// as the namespace of the operator on install nor deleted on uninstall
+			if obj.GetKind() == "Namespace" && namespace != operatorNamespace {
+				continue
+			}
+			moveToNamespace(obj, namespace)
+			objs = append(objs, obj)
+		}
+		r.Close()
+	}
+	return operatorVersion, objs, nil
+}
+
+// ensureNamespace creates the namespace given by --operator-namespace when it does not exist
+func ensureNamespace(ctx context.Context, client dynamic.Interface, namespace string, out io.Writer) error {
+	_, err := client.Resource(namespaceGVR).Get(ctx, namespace, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("Namespace")
+	obj.SetName(namespace)
+	if _, err := client.Resource(namespaceGVR).Create(ctx, obj, metav1.CreateOptions{FieldManager: fieldManager}); err != nil {
+		return fmt.Errorf("failed to create namespace %s: %v", namespace, err)
+	}
+	fmt.Fprintf(out, "namespace/%s created\n", namespace)
+	return nil
+}
+
+// moveToNamespace moves an object of the RHINO operator from its default namespace to the namespace
+func moveToNamespace(obj *unstructured.Unstructured, namespace string) {
+	if obj.GetNamespace() == operatorNamespace {
+		obj.SetNamespace(namespace)
+	}
+	subjects, found, _ := unstructured.NestedSlice(obj.Object, "subjects")
+	if !found {
+		return
+	}
+	for _, subject := range subjects {
+		if subject, ok := subject.(map[string]interface{}); ok && subject["namespace"] == operatorNamespace {
+			subject["namespace"] = namespace
+		}
+	}
+	unstructured.SetNestedSlice(obj.Object, subjects, "subjects")
+}
+
+// resourceFor returns the client of the resource of an object
+func resourceFor(client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the resource of %s: %v", gvk.Kind, err)
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return client.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+	}
+	return client.Resource(mapping.Resource), nil
+}
+
+// applyObjects creates or updates the objects with server-side apply, in order
+func applyObjects(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper,
+	objs []*unstructured.Unstructured, out io.Writer) error {
+	for _, obj := range objs {
+		ri, err := resourceFor(client, mapper, obj)
+		if err != nil {
+			return err
+		}
+		_, err = ri.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+		if err != nil {
+			return fmt.Errorf("failed to apply %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+		fmt.Fprintf(out, "%s/%s applied\n", strings.ToLower(obj.GetKind()), obj.GetName())
+	}
+	return nil
+}
+
+// waitForOperator waits until the CRDs are established and the Deployments are ready
+func waitForOperator(ctx context.Context, client dynamic.Interface, objs []*unstructured.Unstructured) error {
+	var waiting string
+	err := utilwait.PollImmediateUntilWithContext(ctx, installPollInterval, func(ctx context.Context) (bool, error) {
+		for _, obj := range objs {
+			switch obj.GetKind() {
+			case "CustomResourceDefinition":
+				crd, err := client.Resource(crdGVR).Get(ctx, obj.GetName(), metav1.GetOptions{})
+				if err != nil {
+					return false, err
+				}
+				if !crdEstablished(crd) {
+					waiting = "CustomResourceDefinition " + obj.GetName() + " to be established"
+					return false, nil
+				}
+			case "Deployment":
+				deployment, err := client.Resource(deploymentGVR).Namespace(obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
+				if err != nil {
+					return false, err
+				}
+				if !deploymentReady(deployment) {
+					waiting = "Deployment " + obj.GetNamespace() + "/" + obj.GetName() + " to be ready"
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out waiting for %s", waiting)
+	}
+	return err
+}
+
+// crdEstablished tells whether the API server serves the resource of a CRD
+func crdEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, condition := range conditions {
+		if condition, ok := condition.(map[string]interface{}); ok &&
+			condition["type"] == "Established" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+// deploymentReady tells whether the latest version of a Deployment has all its replicas ready
+func deploymentReady(deployment *unstructured.Unstructured) bool {
+	replicas, found, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+	observedGeneration, _, _ := unstructured.NestedInt64(deployment.Object, "status", "observedGeneration")
+	updatedReplicas, _, _ := unstructured.NestedInt64(deployment.Object, "status", "updatedReplicas")
+	readyReplicas, _, _ := unstructured.NestedInt64(deployment.Object, "status", "readyReplicas")
+	return observedGeneration >= deployment.GetGeneration() && updatedReplicas >= replicas && readyReplicas >= replicas
+}
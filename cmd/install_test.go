@@ -0,0 +1,211 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestRESTMapper maps the kinds of the objects to their resources
+func newTestRESTMapper(objs []*unstructured.Unstructured) meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, obj := range objs {
+		scope := meta.RESTScopeNamespace
+		if obj.GetNamespace() == "" {
+			scope = meta.RESTScopeRoot
+		}
+		mapper.Add(obj.GroupVersionKind(), scope)
+	}
+	return mapper
+}
+
+func TestLoadOperatorManifests(t *testing.T) {
+	version, objs, err := loadOperatorManifests("", "rhino-system")
+	assert.Equal(t, nil, err, "test load operator manifests failed: %s", errorMessage(err))
+	assert.Equal(t, "v0.1.0", version)
+
+	kinds := map[string]int{}
+	for _, obj := range objs {
+		kinds[obj.GetKind()]++
+		assert.NotEqual(t, operatorNamespace, obj.GetNamespace(), "%s %s was not moved", obj.GetKind(), obj.GetName())
+		subjects, _, _ := unstructured.NestedSlice(obj.Object, "subjects")
+		for _, subject := range subjects {
+			assert.Equal(t, "rhino-system", subject.(map[string]interface{})["namespace"])
+		}
+		if obj.GetKind() == "Deployment" {
+			assert.Equal(t, operatorDeploymentName, obj.GetName())
+			assert.Equal(t, "rhino-system", obj.GetNamespace())
+		}
+	}
+	assert.Equal(t, 2, kinds["CustomResourceDefinition"])
+	assert.Equal(t, 1, kinds["Deployment"])
+	// the namespace of the operator is only part of the manifests when it is the default one
+	assert.Equal(t, 0, kinds["Namespace"])
+	_, objs, err = loadOperatorManifests("", operatorNamespace)
+	assert.Equal(t, nil, err, "test load operator manifests failed: %s", errorMessage(err))
+	assert.Equal(t, "Namespace", objs[0].GetKind())
+	assert.Equal(t, operatorNamespace, objs[0].GetName())
+
+	_, _, err = loadOperatorManifests("0.1.0", operatorNamespace)
+	assert.Equal(t, nil, err, "test load operator manifests failed: %s", errorMessage(err))
+
+	// the operator of each embedded version is pinned to that version, to match its embedded CRDs
+	versions, err := operatorVersions()
+	assert.Equal(t, nil, err, "test load operator manifests failed: %s", errorMessage(err))
+	for _, v := range versions {
+		_, objs, err := loadOperatorManifests(v, operatorNamespace)
+		assert.Equal(t, nil, err, "test load operator manifests failed: %s", errorMessage(err))
+		for _, obj := range objs {
+			if obj.GetKind() != "Deployment" {
+				continue
+			}
+			deployment := &appsv1.Deployment{}
+			assert.Equal(t, nil, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment))
+			image := operatorImage(deployment)
+			assert.Equal(t, v, operatorVersion(deployment, image))
+			assert.True(t, strings.HasSuffix(image, ":"+v) || strings.Contains(image, "@sha256:"),
+				"the image %s of the RHINO operator %s is not pinned", image, v)
+		}
+	}
+	_, _, err = loadOperatorManifests("v9.9.9", operatorNamespace)
+	assert.Equal(t, "RHINO operator v9.9.9 is not embedded in rhino, the available versions are: v0.1.0", errorMessage(err))
+}
+
+func TestInstallApplyObjects(t *testing.T) {
+	_, objs, err := loadOperatorManifests("", operatorNamespace)
+	assert.Equal(t, nil, err, "test install failed: %s", errorMessage(err))
+	client := newFakeDynamicClient()
+	var applied []string
+	client.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		applied = append(applied, patch.GetResource().Resource+" "+patch.GetNamespace()+" "+patch.GetName())
+		return true, &unstructured.Unstructured{}, nil
+	})
+
+	out := new(bytes.Buffer)
+	err = applyObjects(context.TODO(), client, newTestRESTMapper(objs), objs, out)
+	assert.Equal(t, nil, err, "test install failed: %s", errorMessage(err))
+	assert.Equal(t, len(objs), len(applied))
+	assert.Equal(t, "namespaces  "+operatorNamespace, applied[0])
+	assert.Equal(t, "deployments "+operatorNamespace+" "+operatorDeploymentName, applied[len(applied)-1])
+	assert.Equal(t, "namespace/"+operatorNamespace+" applied", strings.Split(out.String(), "\n")[0])
+}
+
+func TestInstallWaitForOperator(t *testing.T) {
+	_, objs, err := loadOperatorManifests("", operatorNamespace)
+	assert.Equal(t, nil, err, "test install failed: %s", errorMessage(err))
+	var existing []runtime.Object
+	var deployment *unstructured.Unstructured
+	for _, obj := range objs {
+		switch obj.GetKind() {
+		case "CustomResourceDefinition":
+			crd := obj.DeepCopy()
+			unstructured.SetNestedSlice(crd.Object, []interface{}{
+				map[string]interface{}{"type": "Established", "status": "True"},
+			}, "status", "conditions")
+			existing = append(existing, crd)
+		case "Deployment":
+			deployment = obj.DeepCopy()
+			deployment.SetGeneration(2)
+			unstructured.SetNestedField(deployment.Object, int64(2), "status", "observedGeneration")
+			unstructured.SetNestedField(deployment.Object, int64(1), "status", "updatedReplicas")
+		}
+	}
+
+	// the deployment has no ready replica yet
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = waitForOperator(ctx, newFakeDynamicClient(append(existing, deployment.DeepCopy())...), objs)
+	assert.Equal(t, "timed out waiting for Deployment "+operatorNamespace+"/"+operatorDeploymentName+" to be ready", errorMessage(err))
+
+	unstructured.SetNestedField(deployment.Object, int64(1), "status", "readyReplicas")
+	err = waitForOperator(context.Background(), newFakeDynamicClient(append(existing, deployment)...), objs)
+	assert.Equal(t, nil, err, "test install failed: %s", errorMessage(err))
+}
+
+func TestUninstall(t *testing.T) {
+	_, objs, err := loadOperatorManifests("", operatorNamespace)
+	assert.Equal(t, nil, err, "test uninstall failed: %s", errorMessage(err))
+	mapper := newTestRESTMapper(objs)
+
+	// a RHINO job still exists, and the user does not confirm
+	client := newFakeDynamicClient(newTestRhinoJob("test-uninstall", rhinojob.Running))
+	uninstallOpts := &UninstallOptions{}
+	out := new(bytes.Buffer)
+	err = uninstallOpts.uninstall(context.TODO(), client, mapper, objs, strings.NewReader("n\n"), out)
+	assert.Equal(t, nil, err, "test uninstall failed: %s", errorMessage(err))
+	assert.Contains(t, out.String(), "Warning: 1 RhinoJobs still exist")
+	assert.Contains(t, out.String(), "Uninstall cancelled")
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "delete", action.GetVerb())
+	}
+
+	// with --yes, the objects are deleted in the reverse order, and the missing ones are skipped
+	var deployment *unstructured.Unstructured
+	for _, obj := range objs {
+		if obj.GetKind() == "Deployment" {
+			deployment = obj
+		}
+	}
+	client = newFakeDynamicClient(newTestRhinoJob("test-uninstall", rhinojob.Running), deployment.DeepCopy())
+	uninstallOpts.yes = true
+	out = new(bytes.Buffer)
+	err = uninstallOpts.uninstall(context.TODO(), client, mapper, objs, strings.NewReader(""), out)
+	assert.Equal(t, nil, err, "test uninstall failed: %s", errorMessage(err))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, len(objs)+2, len(lines), "unexpected output:\n%s", out.String())
+	assert.Equal(t, "deployment/"+operatorDeploymentName+" deleted", lines[1])
+	assert.Equal(t, "namespace/"+operatorNamespace+" not found", lines[len(lines)-2])
+	assert.Equal(t, "The RHINO operator is uninstalled", lines[len(lines)-1])
+}
+
+// check if a namespace given by --operator-namespace is created when missing, and never deleted
+func TestInstallUninstallCustomNamespace(t *testing.T) {
+	_, objs, err := loadOperatorManifests("", "team-a")
+	assert.Equal(t, nil, err, "test uninstall failed: %s", errorMessage(err))
+	namespace := &unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName("team-a")
+	mapper := newTestRESTMapper(append(objs, namespace))
+
+	client := newFakeDynamicClient()
+	out := new(bytes.Buffer)
+	assert.Equal(t, nil, ensureNamespace(context.TODO(), client, "team-a", out))
+	assert.Equal(t, "namespace/team-a created\n", out.String())
+	out.Reset()
+	assert.Equal(t, nil, ensureNamespace(context.TODO(), client, "team-a", out))
+	assert.Equal(t, "", out.String())
+
+	uninstallOpts := &UninstallOptions{yes: true}
+	err = uninstallOpts.uninstall(context.TODO(), client, mapper, objs, strings.NewReader(""), out)
+	assert.Equal(t, nil, err, "test uninstall failed: %s", errorMessage(err))
+	assert.NotContains(t, out.String(), "namespace/team-a")
+	_, err = client.Tracker().Get(namespaceGVR, "", "team-a")
+	assert.Equal(t, nil, err, "the namespace team-a was deleted: %s", errorMessage(err))
+}
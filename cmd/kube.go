@@ -0,0 +1,172 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubeOptions holds the options shared by all the commands talking to a Kubernetes cluster.
+// The cluster is found with the standard kubeconfig loading rules: the file given by --kubeconfig,
+// or else the files listed in $KUBECONFIG merged together, or else ~/.kube/config, or else the
+// in-cluster service account config when rhino runs inside a pod.
+type KubeOptions struct {
+	kubeconfig string
+	namespace  string
+	overrides  clientcmd.ConfigOverrides
+
+	// The profile used by the command, loaded by complete
+	profile *Profile
+
+	// The RhinoJob resource at the version served by the cluster, discovered by rhinoJobClient
+	rhinoJobGVR schema.GroupVersionResource
+}
+
+// addKubeFlags adds the flags to choose the cluster and the namespace.
+// The flag for the address of the API server is --api-server, since rhino run
+// already uses --server for the NFS server.
+func (k *KubeOptions) addKubeFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&k.namespace, "namespace", "n", "", "namespace of the RHINO job")
+	flags.StringVar(&k.kubeconfig, "kubeconfig", "", "path to the kubeconfig file. By default, the files in $KUBECONFIG or ~/.kube/config are used")
+	flags.StringVar(&k.overrides.CurrentContext, "context", "", "the name of the kubeconfig context to use")
+	flags.StringVar(&k.overrides.Context.Cluster, "cluster", "", "the name of the kubeconfig cluster to use")
+	flags.StringVar(&k.overrides.Context.AuthInfo, "user", "", "the name of the kubeconfig user to use")
+	flags.StringVar(&k.overrides.AuthInfo.Token, "token", "", "bearer token for authentication to the API server")
+	flags.StringVar(&k.overrides.ClusterInfo.Server, "api-server", "", "the address and port of the Kubernetes API server")
+	flags.BoolVar(&k.overrides.ClusterInfo.InsecureSkipTLSVerify, "insecure-skip-tls-verify", false, "do not check the certificate of the API server. This will make your HTTPS connections insecure")
+	flags.StringVar(&k.overrides.AuthInfo.Impersonate, "as", "", "username to impersonate for the operation")
+	flags.StringArrayVar(&k.overrides.AuthInfo.ImpersonateGroups, "as-group", nil, "group to impersonate for the operation, this flag can be repeated to specify multiple groups")
+}
+
+// complete fills the options not given on the command line from the profile,
+// then resolves the namespace from the kubeconfig context or the pod rhino runs in
+func (k *KubeOptions) complete(cmd *cobra.Command) error {
+	profile, err := loadProfile(cmd)
+	if err != nil {
+		return err
+	}
+	k.profile = profile
+	if k.kubeconfig == "" {
+		k.kubeconfig = profile.Kubeconfig
+	}
+	if k.overrides.CurrentContext == "" {
+		k.overrides.CurrentContext = profile.Context
+	}
+	if k.namespace == "" {
+		k.namespace = profile.Namespace
+	}
+	if k.namespace == "" {
+		namespace, _, err := k.clientConfig().Namespace()
+		if err != nil {
+			return kubeconfigError(err)
+		}
+		k.namespace = namespace
+	}
+	return nil
+}
+
+func (k *KubeOptions) clientConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = k.kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &k.overrides)
+}
+
+func (k *KubeOptions) restConfig() (*rest.Config, error) {
+	config, err := k.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, kubeconfigError(err)
+	}
+	return config, nil
+}
+
+// kubeconfigError explains how to provide a cluster when none is configured
+func kubeconfigError(err error) error {
+	if clientcmd.IsEmptyConfig(err) {
+		return fmt.Errorf("no Kubernetes cluster configured, please set $KUBECONFIG, use --kubeconfig or run rhino inside a pod")
+	}
+	return err
+}
+
+func (k *KubeOptions) dynamicClient() (dynamic.Interface, error) {
+	config, err := k.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(config)
+}
+
+// clientset returns a typed clientset for the built-in resources such as pods and events
+func (k *KubeOptions) clientset() (kubernetes.Interface, error) {
+	config, err := k.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// rhinoJobClient returns a dynamic client, after discovering the version of the RhinoJob API to use
+func (k *KubeOptions) rhinoJobClient() (dynamic.Interface, error) {
+	discoveryClient, err := k.discoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	api, err := discoverRhinoJobAPI(discoveryClient)
+	if err != nil {
+		return nil, err
+	}
+	if k.rhinoJobGVR, err = api.resource(); err != nil {
+		return nil, err
+	}
+	return k.dynamicClient()
+}
+
+// rhinoJobResource returns the RhinoJob resource at the discovered version,
+// or at the version preferred by rhino if it has not been discovered
+func (k *KubeOptions) rhinoJobResource() schema.GroupVersionResource {
+	if k.rhinoJobGVR.Empty() {
+		return RhinoJobGVR
+	}
+	return k.rhinoJobGVR
+}
+
+func (k *KubeOptions) discoveryClient() (discovery.DiscoveryInterface, error) {
+	config, err := k.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	return discovery.NewDiscoveryClientForConfig(config)
+}
+
+// restMapper maps the kinds of objects to their resources, discovering the resources served by the cluster
+func (k *KubeOptions) restMapper() (meta.RESTMapper, error) {
+	discoveryClient, err := k.discoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	return restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)), nil
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestKubeOptions parses the kube flags into new KubeOptions and completes them
+func newTestKubeOptions(t *testing.T, args ...string) (*KubeOptions, error) {
+	kubeOpts := &KubeOptions{}
+	cmd := &cobra.Command{}
+	kubeOpts.addKubeFlags(cmd.Flags())
+	assert.Equal(t, nil, cmd.Flags().Parse(args))
+	return kubeOpts, kubeOpts.complete(cmd)
+}
+
+func TestKubeconfigMerging(t *testing.T) {
+	useTestUserConfig(t)
+	dir := t.TempDir()
+	clusters := filepath.Join(dir, "clusters")
+	err := os.WriteFile(clusters, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: cluster-a
+  cluster:
+    server: https://10.0.0.1:6443
+- name: cluster-b
+  cluster:
+    server: https://10.0.0.2:6443
+users:
+- name: user-a
+  user:
+    token: token-a
+`), 0600)
+	assert.Equal(t, nil, err)
+	contexts := filepath.Join(dir, "contexts")
+	err = os.WriteFile(contexts, []byte(`apiVersion: v1
+kind: Config
+contexts:
+- name: context-a
+  context:
+    cluster: cluster-a
+    user: user-a
+    namespace: namespace-a
+- name: context-b
+  context:
+    cluster: cluster-b
+    user: user-a
+current-context: context-a
+`), 0600)
+	assert.Equal(t, nil, err)
+	t.Setenv("KUBECONFIG", strings.Join([]string{clusters, contexts}, string(os.PathListSeparator)))
+
+	kubeOpts, err := newTestKubeOptions(t)
+	assert.Equal(t, nil, err, "test kubeconfig failed: %s", errorMessage(err))
+	assert.Equal(t, "namespace-a", kubeOpts.namespace)
+	config, err := kubeOpts.restConfig()
+	assert.Equal(t, nil, err, "test kubeconfig failed: %s", errorMessage(err))
+	assert.Equal(t, "https://10.0.0.1:6443", config.Host)
+	assert.Equal(t, "token-a", config.BearerToken)
+
+	kubeOpts, err = newTestKubeOptions(t, "--context", "context-b")
+	assert.Equal(t, nil, err, "test kubeconfig failed: %s", errorMessage(err))
+	assert.Equal(t, "default", kubeOpts.namespace)
+	config, err = kubeOpts.restConfig()
+	assert.Equal(t, nil, err, "test kubeconfig failed: %s", errorMessage(err))
+	assert.Equal(t, "https://10.0.0.2:6443", config.Host)
+
+	kubeOpts, err = newTestKubeOptions(t, "--cluster", "cluster-b", "--api-server", "https://10.0.0.3:6443",
+		"--token", "token-b", "--as", "alice", "--as-group", "team-a", "--as-group", "team-b", "--insecure-skip-tls-verify")
+	assert.Equal(t, nil, err, "test kubeconfig failed: %s", errorMessage(err))
+	config, err = kubeOpts.restConfig()
+	assert.Equal(t, nil, err, "test kubeconfig failed: %s", errorMessage(err))
+	assert.Equal(t, "https://10.0.0.3:6443", config.Host)
+	assert.Equal(t, "token-b", config.BearerToken)
+	assert.Equal(t, "alice", config.Impersonate.UserName)
+	assert.Equal(t, []string{"team-a", "team-b"}, config.Impersonate.Groups)
+	assert.Equal(t, true, config.Insecure)
+}
+
+// check if a missing context is reported instead of leaving the namespace empty
+func TestKubeconfigMissingContext(t *testing.T) {
+	useTestUserConfig(t)
+	kubeconfig := writeTestKubeconfig(t, testFuncRunNamespace)
+	_, err := newTestKubeOptions(t, "--kubeconfig", kubeconfig, "--context", "missing")
+	assert.Contains(t, errorMessage(err), `context was not found for specified context: missing`)
+}
+
+func TestKubeconfigNotFound(t *testing.T) {
+	useTestUserConfig(t)
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	kubeOpts, err := newTestKubeOptions(t, "-n", testFuncRunNamespace)
+	assert.Equal(t, nil, err, "test kubeconfig failed: %s", errorMessage(err))
+	_, err = kubeOpts.restConfig()
+	assert.Equal(t, "no Kubernetes cluster configured, please set $KUBECONFIG, use --kubeconfig or run rhino inside a pod", errorMessage(err))
+}
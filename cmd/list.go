@@ -19,20 +19,39 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
 	"github.com/spf13/cobra"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/util/homedir"
+	"k8s.io/client-go/util/jsonpath"
 )
 
 type ListOptions struct {
-	kubeconfig string
-	namespace  string
+	output string
+	sortBy string
+	watch  bool
+
+	FilterOptions
+	KubeOptions
+}
+
+// customColumn is a column of the custom-columns output format
+type customColumn struct {
+	header string
+	path   *jsonpath.JSONPath
 }
 
 func NewListCommand() *cobra.Command {
@@ -42,12 +61,21 @@ func NewListCommand() *cobra.Command {
 		Short: "List RHINO jobs",
 		Long:  "\nList all the RHINO jobs in your current namespace or the namespace specified",
 		Example: `  rhino list
-  rhino list --namespace user_func`,
+  rhino list --namespace user_func
+  rhino list -A --status Failed
+  rhino list -l team=hpc --image 'foo/*'
+  rhino list -o wide --sort-by .metadata.creationTimestamp
+  rhino list -o name | xargs -n 1 rhino delete
+  rhino list --watch
+  rhino list -o custom-columns=NAME:.metadata.name,IMAGE:.spec.image,NP:.spec.parallelism`,
 		RunE: listOpts.list,
 	}
 
-	listCmd.Flags().StringVarP(&listOpts.namespace, "namespace", "n", "", "the namespace to list RHINO jobs")
-	listCmd.Flags().StringVar(&listOpts.kubeconfig, "kubeconfig", "", "the path of the kubeconfig file")
+	listOpts.addKubeFlags(listCmd.Flags())
+	listOpts.addFilterFlags(listCmd.Flags())
+	listCmd.Flags().StringVarP(&listOpts.output, "output", "o", "", "output format: wide, json, yaml, name or custom-columns=<header>:<json-path>[,<header>:<json-path>...]")
+	listCmd.Flags().BoolVarP(&listOpts.watch, "watch", "w", false, "after listing the RHINO jobs, watch for changes and print the jobs added, modified or deleted")
+	listCmd.Flags().StringVar(&listOpts.sortBy, "sort-by", "", "sort the RHINO jobs by a JSONPath expression, e.g. .metadata.creationTimestamp or .spec.parallelism")
 
 	return listCmd
 }
@@ -58,59 +86,472 @@ func (l *ListOptions) list(cmd *cobra.Command, args []string) error {
 		cmd.Help()
 		return nil
 	}
-
-	// Get the kubeconfig file
-	if len(l.kubeconfig) == 0 {
-		if home := homedir.HomeDir(); home != "" {
-			l.kubeconfig = filepath.Join(home, ".kube", "config")
-		} else {
-			return fmt.Errorf("kubeconfig file not found, please use --config to specify the absolute path")
-		}
+	if _, err := l.customColumns(); err != nil {
+		return err
+	}
+	if err := l.validate(); err != nil {
+		return err
 	}
 
 	// Build the dynamic client
-	dynamicClient, currentNamespace, err := buildFromKubeconfig(l.kubeconfig)
-	if err != nil {
+	if err := l.complete(cmd); err != nil {
 		return err
 	}
-	if l.namespace == "" {
-		l.namespace = *currentNamespace
+	dynamicClient, err := l.rhinoJobClient()
+	if err != nil {
+		return err
 	}
 	list, err := l.listRhinoJob(dynamicClient)
 	if err != nil {
 		return err
 	}
-	if len(list.Items) == 0 {
-		fmt.Println("Warning: no RhinoJobs found in the namespace")
-	} else {
-		w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "Name\tParallelism\tStatus")
+	if err := l.printRhinoJobs(cmd.OutOrStdout(), list, time.Now()); err != nil {
+		return err
+	}
+	if l.watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return l.watchRhinoJobs(ctx, dynamicClient, cmd.OutOrStdout(), list)
+	}
+	return nil
+}
+
+// customColumns checks the output format, and parses the columns of the custom-columns format
+func (l *ListOptions) customColumns() ([]customColumn, error) {
+	switch l.output {
+	case "", "wide", "json", "yaml", "name":
+		return nil, nil
+	}
+	if !strings.HasPrefix(l.output, "custom-columns=") {
+		return nil, fmt.Errorf("the output format (-o) must be wide, json, yaml, name or custom-columns=<header>:<json-path>,...")
+	}
+	var columns []customColumn
+	for _, column := range strings.Split(strings.TrimPrefix(l.output, "custom-columns="), ",") {
+		header, expr, ok := strings.Cut(column, ":")
+		if !ok || header == "" || expr == "" {
+			return nil, fmt.Errorf("invalid custom column %q, should be <header>:<json-path>", column)
+		}
+		path, err := parseJSONPath(header, expr)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, customColumn{header: header, path: path})
+	}
+	return columns, nil
+}
+
+// parseJSONPath parses a JSONPath expression, accepting ".spec.image" as well as "{.spec.image}"
+func parseJSONPath(name string, expr string) (*jsonpath.JSONPath, error) {
+	if !strings.HasPrefix(expr, "{") {
+		expr = "{" + expr + "}"
+	}
+	path := jsonpath.New(name).AllowMissingKeys(true)
+	if err := path.Parse(expr); err != nil {
+		return nil, fmt.Errorf("invalid JSONPath expression %q: %v", expr, err)
+	}
+	return path, nil
+}
+
+// printRhinoJobs writes the RHINO jobs to out in the output format
+func (l *ListOptions) printRhinoJobs(out io.Writer, list *rhinojob.RhinoJobList, now time.Time) error {
+	columns, err := l.customColumns()
+	if err != nil {
+		return err
+	}
+	if l.sortBy != "" {
+		if err := sortRhinoJobs(list.Items, l.sortBy); err != nil {
+			return err
+		}
+	}
 
+	switch l.output {
+	case "json", "yaml":
+		// When watching, the jobs are printed one by one like the changes that follow
+		if l.watch {
+			for _, rj := range list.Items {
+				if err := l.printRhinoJobEvent(out, columns, watch.Added, rj, now); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		list.APIVersion = rhinojob.GroupVersion.String()
+		list.Kind = "RhinoJobList"
+		return printObject(out, list, l.output)
+	case "name":
 		for _, rj := range list.Items {
-			fmt.Fprintf(w, "%s\t%d\t%s\n", rj.Name, *rj.Spec.Parallelism, rj.Status.JobStatus)
+			fmt.Fprintln(out, rj.Name)
 		}
+		return nil
+	}
 
-		// 刷新输出，确保所有内容写入 stdout
-		if err := w.Flush(); err != nil {
+	if len(list.Items) == 0 && !l.watch {
+		fmt.Fprintln(out, l.noRhinoJobsWarning())
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 2, 0, 2, ' ', 0)
+	l.printHeader(w, columns)
+	for _, rj := range list.Items {
+		if err := l.printRow(w, columns, watch.Added, rj, now); err != nil {
 			return err
 		}
 	}
 
+	// 刷新输出，确保所有内容写入 stdout
+	return w.Flush()
+}
+
+// noRhinoJobsWarning tells that no RHINO jobs were found where they were looked for
+func (l *ListOptions) noRhinoJobsWarning() string {
+	where := "in the namespace"
+	if l.allNamespaces {
+		where = "in any namespace"
+	}
+	if l.isSet() {
+		where += " matching the filters"
+	}
+	return "Warning: no RhinoJobs found " + where
+}
+
+// printRhinoJobEvent writes a RHINO job changed while watching to out in the output format
+func (l *ListOptions) printRhinoJobEvent(out io.Writer, columns []customColumn, eventType watch.EventType,
+	rj rhinojob.RhinoJob, now time.Time) error {
+	switch l.output {
+	case "json", "yaml":
+		rj.APIVersion = rhinojob.GroupVersion.String()
+		rj.Kind = "RhinoJob"
+		if l.output == "yaml" {
+			fmt.Fprintln(out, "---")
+		}
+		return printObject(out, &rj, l.output)
+	case "name":
+		_, err := fmt.Fprintln(out, rj.Name)
+		return err
+	}
+	w := tabwriter.NewWriter(out, 2, 0, 2, ' ', 0)
+	if err := l.printRow(w, columns, eventType, rj, now); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// printHeader writes the header of the table, with an extra Event column when watching
+func (l *ListOptions) printHeader(w io.Writer, columns []customColumn) {
+	if l.watch {
+		fmt.Fprint(w, "Event\t")
+	}
+	if l.allNamespaces {
+		fmt.Fprint(w, "Namespace\t")
+	}
+	switch {
+	case columns != nil:
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			headers[i] = column.header
+		}
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+	case l.output == "wide":
+		fmt.Fprintln(w, "Name\tParallelism\tStatus\tImage\tTTL\tAge\tNFS Server\tApp Args")
+	default:
+		fmt.Fprintln(w, "Name\tParallelism\tStatus")
+	}
+}
+
+// printRow writes a RHINO job as a row of the table
+func (l *ListOptions) printRow(w io.Writer, columns []customColumn, eventType watch.EventType,
+	rj rhinojob.RhinoJob, now time.Time) error {
+	if l.watch {
+		fmt.Fprintf(w, "%s\t", eventType)
+	}
+	if l.allNamespaces {
+		fmt.Fprintf(w, "%s\t", rj.Namespace)
+	}
+	switch {
+	case columns != nil:
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&rj)
+		if err != nil {
+			return err
+		}
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			if values[i], err = formatJSONPath(column.path, obj); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	case l.output == "wide":
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", rj.Name, formatInt32(rj.Spec.Parallelism),
+			formatJobStatus(rj.Status.JobStatus), rj.Spec.Image, formatTTLLeft(rj, now),
+			duration.HumanDuration(now.Sub(rj.CreationTimestamp.Time)), formatOptional(rj.Spec.DataServer),
+			formatAppArgs(rj.Spec.AppArgs))
+	default:
+		fmt.Fprintf(w, "%s\t%s\t%s\n", rj.Name, formatInt32(rj.Spec.Parallelism), rj.Status.JobStatus)
+	}
 	return nil
 }
 
-func (l *ListOptions) listRhinoJob(client dynamic.Interface) (*rhinojob.RhinoJobList, error) {
-	list, err := client.Resource(RhinoJobGVR).Namespace(l.namespace).List(context.TODO(), metav1.ListOptions{})
+// watchRhinoJobs prints the changes of the RHINO jobs after the list until the context is done.
+// It watches from the version of the list and follows the bookmarks sent by the server,
+// so that a closed watch is resumed without listing the jobs again. The jobs are only
+// listed again when the version to resume from has expired.
+func (l *ListOptions) watchRhinoJobs(ctx context.Context, client dynamic.Interface, out io.Writer,
+	list *rhinojob.RhinoJobList) error {
+	columns, err := l.customColumns()
+	if err != nil {
+		return err
+	}
+	rjClient := client.Resource(l.rhinoJobResource()).Namespace(l.listNamespace(l.namespace))
+	// The jobs passing the filters, by namespace and name
+	known := make(map[string]rhinojob.RhinoJob, len(list.Items))
+	for _, rj := range list.Items {
+		known[rj.Namespace+"/"+rj.Name] = rj
+	}
+	resourceVersion := list.ResourceVersion
+
+	for {
+		listOptions := l.listOptions()
+		listOptions.ResourceVersion = resourceVersion
+		listOptions.AllowWatchBookmarks = true
+		watcher, err := rjClient.Watch(ctx, listOptions)
+		if ctx.Err() != nil {
+			return nil
+		}
+		expired := apierrors.IsResourceExpired(err) || apierrors.IsGone(err)
+		if err != nil && !expired {
+			return err
+		}
+		if !expired {
+			expired, err = func() (bool, error) {
+				defer watcher.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return false, nil
+					case event, ok := <-watcher.ResultChan():
+						if !ok {
+							return false, nil
+						}
+						switch event.Type {
+						case watch.Error:
+							// Restart the watch, listing the jobs again if the version has expired
+							err := apierrors.FromObject(event.Object)
+							return apierrors.IsResourceExpired(err) || apierrors.IsGone(err), nil
+						case watch.Bookmark:
+							if obj, ok := event.Object.(*unstructured.Unstructured); ok {
+								resourceVersion = obj.GetResourceVersion()
+							}
+						case watch.Added, watch.Modified, watch.Deleted:
+							obj, ok := event.Object.(*unstructured.Unstructured)
+							if !ok {
+								continue
+							}
+							rj, err := rhinoJobFromUnstructured(obj)
+							if err != nil {
+								return false, err
+							}
+							resourceVersion = rj.ResourceVersion
+							key := rj.Namespace + "/" + rj.Name
+							_, wasKnown := known[key]
+							eventType := event.Type
+							if eventType != watch.Deleted && !l.matches(rj) {
+								// A job no longer passing the filters leaves the list like a deleted one
+								eventType = watch.Deleted
+							}
+							if eventType == watch.Deleted {
+								if !wasKnown {
+									continue
+								}
+								delete(known, key)
+							} else {
+								known[key] = rj
+							}
+							if err := l.printRhinoJobEvent(out, columns, eventType, rj, time.Now()); err != nil {
+								return false, err
+							}
+						}
+					}
+				}
+			}()
+			if err != nil {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if expired {
+			if resourceVersion, err = l.relistRhinoJobs(out, client, columns, known); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// relistRhinoJobs lists the RHINO jobs again after the watch expired, and prints
+// the jobs added, modified and deleted since the last known version.
+// It returns the version of the new list to resume watching from.
+func (l *ListOptions) relistRhinoJobs(out io.Writer, client dynamic.Interface, columns []customColumn,
+	known map[string]rhinojob.RhinoJob) (string, error) {
+	list, err := l.listRhinoJob(client)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	now := time.Now()
+	listed := make(map[string]bool, len(list.Items))
+	for _, rj := range list.Items {
+		key := rj.Namespace + "/" + rj.Name
+		listed[key] = true
+		eventType := watch.Modified
+		if last, ok := known[key]; !ok {
+			eventType = watch.Added
+		} else if last.ResourceVersion == rj.ResourceVersion {
+			continue
+		}
+		known[key] = rj
+		if err := l.printRhinoJobEvent(out, columns, eventType, rj, now); err != nil {
+			return "", err
+		}
+	}
+	var deleted []string
+	for key := range known {
+		if !listed[key] {
+			deleted = append(deleted, key)
+		}
+	}
+	sort.Strings(deleted)
+	for _, key := range deleted {
+		if err := l.printRhinoJobEvent(out, columns, watch.Deleted, known[key], now); err != nil {
+			return "", err
+		}
+		delete(known, key)
 	}
-	data, err := list.MarshalJSON()
+	return list.ResourceVersion, nil
+}
+
+// formatJSONPath prints the results of a JSONPath expression on an object, or "<none>" if there is none
+func formatJSONPath(path *jsonpath.JSONPath, obj interface{}) (string, error) {
+	results, err := path.FindResults(obj)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	var values []string
+	for _, result := range results {
+		for _, value := range result {
+			if value.Kind() == reflect.Interface && value.IsNil() {
+				continue
+			}
+			if text, err := valueToText(value); err != nil {
+				return "", err
+			} else {
+				values = append(values, text)
+			}
+		}
 	}
-	var rjList rhinojob.RhinoJobList
-	if err := json.Unmarshal(data, &rjList); err != nil {
-		return nil, err
+	if len(values) == 0 {
+		return "<none>", nil
 	}
-	return &rjList, nil
+	return strings.Join(values, ","), nil
+}
+
+// valueToText prints a value found by a JSONPath expression, using JSON for maps and slices
+func valueToText(value reflect.Value) (string, error) {
+	v := value.Interface()
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+	return fmt.Sprint(v), nil
+}
+
+// ttlLeft returns the time left before the operator deletes a RHINO job, which is not positive once its TTL
+// has expired. It returns false when the job is never deleted, because its TTL is unset or 0.
+func ttlLeft(rj rhinojob.RhinoJob, now time.Time) (time.Duration, bool) {
+	if rj.Spec.TTL == nil || *rj.Spec.TTL == 0 {
+		return 0, false
+	}
+	return rj.CreationTimestamp.Add(time.Duration(*rj.Spec.TTL) * time.Second).Sub(now), true
+}
+
+// formatTTLLeft shows the time left before the operator deletes a RHINO job
+func formatTTLLeft(rj rhinojob.RhinoJob, now time.Time) string {
+	left, ok := ttlLeft(rj, now)
+	switch {
+	case rj.Spec.TTL == nil:
+		return "<unset>"
+	case !ok:
+		return "<never>"
+	case left <= 0:
+		return "expired"
+	}
+	return duration.HumanDuration(left)
+}
+
+// sortRhinoJobs sorts the RHINO jobs by the value of a JSONPath expression,
+// comparing numbers numerically and other values as strings. Jobs without the value come last.
+func sortRhinoJobs(items []rhinojob.RhinoJob, expr string) error {
+	path, err := parseJSONPath("sort-by", expr)
+	if err != nil {
+		return err
+	}
+	keys := make([]interface{}, len(items))
+	for i := range items {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&items[i])
+		if err != nil {
+			return err
+		}
+		results, err := path.FindResults(obj)
+		if err != nil {
+			return err
+		}
+		if len(results) > 0 && len(results[0]) > 0 {
+			keys[i] = results[0][0].Interface()
+		}
+	}
+	sort.Stable(rhinoJobsByKey{items: items, keys: keys})
+	return nil
+}
+
+// rhinoJobsByKey sorts RHINO jobs together with their sort keys
+type rhinoJobsByKey struct {
+	items []rhinojob.RhinoJob
+	keys  []interface{}
+}
+
+func (s rhinoJobsByKey) Len() int           { return len(s.items) }
+func (s rhinoJobsByKey) Less(i, j int) bool { return lessSortKey(s.keys[i], s.keys[j]) }
+func (s rhinoJobsByKey) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+func lessSortKey(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a != nil
+	}
+	fa, aIsNumber := toFloat(a)
+	fb, bIsNumber := toFloat(b)
+	if aIsNumber && bIsNumber {
+		return fa < fb
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// listRhinoJob lists the RHINO jobs selected by the filters
+func (l *ListOptions) listRhinoJob(client dynamic.Interface) (*rhinojob.RhinoJobList, error) {
+	return l.listRhinoJobs(client, l.rhinoJobResource(), l.namespace)
 }
@@ -16,14 +16,22 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
 )
 
 func TestListSingleJob(t *testing.T) {
@@ -44,7 +52,7 @@ func TestListSingleJob(t *testing.T) {
 
 	// test run command
 	execShellCmd("kubectl", []string{"create", "namespace", testFuncRunNamespace})
-	rootCmd.SetArgs([]string{"run", testFuncImageName, "--namespace", testFuncRunNamespace})
+	rootCmd.SetArgs([]string{"run", testFuncImageName, "--name", testFuncName, "--namespace", testFuncRunNamespace})
 	err = rootCmd.Execute()
 	assert.Equal(t, nil, err, "preparatory work run failed: %s", errorMessage(err))
 	fmt.Println("Wait 10s and check job status")
@@ -92,3 +100,160 @@ func TestListSingleJob(t *testing.T) {
 	execShellCmd("docker", []string{"rmi", testFuncImageName})
 	execShellCmd("sh", []string{"-c", "docker rmi -f $(docker images | grep none | grep second | awk '{print $3}')"})
 }
+
+func newTestRhinoJobList(now time.Time) *rhinojob.RhinoJobList {
+	np2, np8, ttl := int32(2), int32(8), int32(600)
+	return &rhinojob.RhinoJobList{Items: []rhinojob.RhinoJob{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-list-b", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Minute))},
+			Spec: rhinojob.RhinoJobSpec{Image: "foo/b:v1", Parallelism: &np8, TTL: &ttl,
+				DataServer: "10.0.0.7", DataPath: "/mnt", AppArgs: []string{"--in", "a b"}},
+			Status: rhinojob.RhinoJobStatus{JobStatus: rhinojob.Running},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-list-a", CreationTimestamp: metav1.NewTime(now.Add(-20 * time.Minute))},
+			Spec:       rhinojob.RhinoJobSpec{Image: "foo/a:v1", Parallelism: &np2, TTL: &ttl},
+			Status:     rhinojob.RhinoJobStatus{JobStatus: rhinojob.Completed},
+		},
+	}}
+}
+
+func TestListOutputFormats(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, testcase := range []struct {
+		output   string
+		sortBy   string
+		expected []string
+	}{
+		{"", "", []string{`Name\s+Parallelism\s+Status`, `test-list-b\s+8\s+Running`, `test-list-a\s+2\s+Completed`}},
+		{"wide", "", []string{
+			`Name\s+Parallelism\s+Status\s+Image\s+TTL\s+Age\s+NFS Server\s+App Args`,
+			`test-list-b\s+8\s+Running\s+foo/b:v1\s+8m\s+2m\s+10.0.0.7\s+"--in" "a b"`,
+			`test-list-a\s+2\s+Completed\s+foo/a:v1\s+expired\s+20m\s+<none>\s+<none>`,
+		}},
+		{"name", "", []string{`^test-list-b\ntest-list-a\n$`}},
+		{"name", ".metadata.name", []string{`^test-list-a\ntest-list-b\n$`}},
+		{"name", ".spec.parallelism", []string{`^test-list-a\ntest-list-b\n$`}},
+		{"name", "{.metadata.creationTimestamp}", []string{`^test-list-a\ntest-list-b\n$`}},
+		{"custom-columns=NAME:.metadata.name,NP:.spec.parallelism,SERVER:.spec.dataServer", "", []string{
+			`NAME\s+NP\s+SERVER`, `test-list-b\s+8\s+10.0.0.7`, `test-list-a\s+2\s+<none>`,
+		}},
+	} {
+		listOpts := &ListOptions{output: testcase.output, sortBy: testcase.sortBy}
+		out := new(bytes.Buffer)
+		err := listOpts.printRhinoJobs(out, newTestRhinoJobList(now), now)
+		assert.Equal(t, nil, err, "test list -o %s failed: %s", testcase.output, errorMessage(err))
+		for _, expected := range testcase.expected {
+			assert.Regexp(t, regexp.MustCompile(expected), out.String(), "test list -o %s --sort-by %s", testcase.output, testcase.sortBy)
+		}
+	}
+}
+
+func TestListOutputYaml(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	listOpts := &ListOptions{output: "yaml"}
+	out := new(bytes.Buffer)
+	err := listOpts.printRhinoJobs(out, newTestRhinoJobList(now), now)
+	assert.Equal(t, nil, err, "test list -o yaml failed: %s", errorMessage(err))
+
+	var list rhinojob.RhinoJobList
+	assert.Equal(t, nil, yaml.UnmarshalStrict(out.Bytes(), &list))
+	assert.Equal(t, "RhinoJobList", list.Kind)
+	assert.Equal(t, 2, len(list.Items))
+	assert.Equal(t, "test-list-b", list.Items[0].Name)
+	assert.Equal(t, []string{"--in", "a b"}, list.Items[0].Spec.AppArgs)
+}
+
+func TestListInvalidOutput(t *testing.T) {
+	for output, expected := range map[string]string{
+		"table":                    "the output format (-o) must be wide, json, yaml, name or custom-columns=<header>:<json-path>,...",
+		"custom-columns=NAME":      `invalid custom column "NAME", should be <header>:<json-path>`,
+		"custom-columns=NAME:.a[(": `invalid JSONPath expression "{.a[(}"`,
+	} {
+		listOpts := &ListOptions{output: output}
+		_, err := listOpts.customColumns()
+		assert.Contains(t, errorMessage(err), expected)
+	}
+}
+
+// check if the watch follows bookmarks, and lists the jobs again once the version expires
+func TestListWatch(t *testing.T) {
+	client := newFakeDynamicClient(newTestRhinoJob("test-watch-a", rhinojob.Pending))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var resourceVersions []string
+	client.PrependWatchReactor("rhinojobs", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		resourceVersions = append(resourceVersions, action.(k8stesting.WatchAction).GetWatchRestrictions().ResourceVersion)
+		watcher := watch.NewRaceFreeFake()
+		switch len(resourceVersions) {
+		case 1:
+			running := newTestRhinoJob("test-watch-a", rhinojob.Running)
+			running.SetResourceVersion("2")
+			watcher.Modify(running)
+			added := newTestRhinoJob("test-watch-b", rhinojob.Pending)
+			added.SetResourceVersion("3")
+			watcher.Add(added)
+			bookmark := newTestRhinoJob("", "")
+			bookmark.SetResourceVersion("5")
+			watcher.Action(watch.Bookmark, bookmark)
+			watcher.Stop()
+		case 2:
+			watcher.Error(&metav1.Status{Status: metav1.StatusFailure, Code: 410, Reason: metav1.StatusReasonExpired})
+		default:
+			cancel()
+		}
+		return true, watcher, nil
+	})
+
+	listOpts := &ListOptions{watch: true, KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	list, err := listOpts.listRhinoJob(client)
+	assert.Equal(t, nil, err, "test list --watch failed: %s", errorMessage(err))
+	out := new(bytes.Buffer)
+	err = listOpts.printRhinoJobs(out, list, time.Now())
+	assert.Equal(t, nil, err, "test list --watch failed: %s", errorMessage(err))
+	err = listOpts.watchRhinoJobs(ctx, client, out, list)
+	assert.Equal(t, nil, err, "test list --watch failed: %s", errorMessage(err))
+
+	assert.Equal(t, []string{"", "5", ""}, resourceVersions)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	expected := []string{
+		`^Event\s+Name\s+Parallelism\s+Status$`,
+		`^ADDED\s+test-watch-a\s+<unset>\s+Pending$`,
+		`^MODIFIED\s+test-watch-a\s+<unset>\s+Running$`,
+		`^ADDED\s+test-watch-b\s+<unset>\s+Pending$`,
+		// listed again after the version expired
+		`^MODIFIED\s+test-watch-a\s+<unset>\s+Pending$`,
+		`^DELETED\s+test-watch-b\s+<unset>\s+Pending$`,
+	}
+	assert.Equal(t, len(expected), len(lines), "unexpected output:\n%s", out.String())
+	for i := 0; i < len(expected) && i < len(lines); i++ {
+		assert.Regexp(t, regexp.MustCompile(expected[i]), lines[i])
+	}
+}
+
+func TestTTLLeft(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	rj := rhinojob.RhinoJob{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-time.Minute))}}
+	seconds := func(v int32) *int32 { return &v }
+	tests := []struct {
+		ttl      *int32
+		left     time.Duration
+		ok       bool
+		list     string
+		describe string
+	}{
+		{nil, 0, false, "<unset>", "<unset>"},
+		{seconds(0), 0, false, "<never>", "0s (never deleted)"},
+		{seconds(600), 9 * time.Minute, true, "9m", "600s (9m left)"},
+		{seconds(30), -30 * time.Second, true, "expired", "30s (expired, deleting)"},
+	}
+	for _, test := range tests {
+		rj.Spec.TTL = test.ttl
+		left, ok := ttlLeft(rj, now)
+		assert.Equal(t, test.left, left)
+		assert.Equal(t, test.ok, ok)
+		assert.Equal(t, test.list, formatTTLLeft(rj, now))
+		assert.Equal(t, test.describe, formatTTL(rj, now))
+	}
+}
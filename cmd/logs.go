@@ -0,0 +1,237 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The operator runs a RHINO job as a launcher Job and a workers Job owned by the RhinoJob.
+// Kubernetes labels the pods of a Job with the name of the Job and, for the indexed
+// workers Job, annotates them with their completion index, which is the MPI rank.
+const (
+	jobNameLabel                 = "job-name"
+	jobCompletionIndexAnnotation = "batch.kubernetes.io/job-completion-index"
+	launcherJobSuffix            = "-launcher"
+	workersJobSuffix             = "-workers"
+)
+
+type LogsOptions struct {
+	rhinojobName string
+	follow       bool
+	since        time.Duration
+	tail         int64
+	KubeOptions
+}
+
+func NewLogsCommand() *cobra.Command {
+	logsOpts := &LogsOptions{}
+	logsCmd := &cobra.Command{
+		Use:   "logs [name]",
+		Short: "Print the logs of a RHINO job",
+		Long:  "\nPrint the logs of the launcher and worker pods of a RHINO job, each line prefixed with the pod it comes from",
+		Example: `  rhino logs hello
+  rhino logs matmul --follow --namespace user_space
+  rhino logs mpibench --since 10m --tail 100`,
+		Args: logsOpts.argsCheck,
+		RunE: logsOpts.runLogs,
+	}
+
+	logsCmd.Flags().BoolVarP(&logsOpts.follow, "follow", "f", false, "keep streaming the logs until the pods exit")
+	logsCmd.Flags().DurationVar(&logsOpts.since, "since", 0, "only print logs newer than a relative duration like 5s, 2m, or 3h")
+	logsCmd.Flags().Int64Var(&logsOpts.tail, "tail", -1, "the number of most recent lines to print for each pod. -1 prints all lines")
+	logsOpts.addKubeFlags(logsCmd.Flags())
+
+	return logsCmd
+}
+
+func (l *LogsOptions) argsCheck(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("[name] cannot be empty")
+	}
+	l.rhinojobName = args[0]
+	if l.since < 0 {
+		return fmt.Errorf("--since must be greater than or equal to 0")
+	}
+
+	return nil
+}
+
+func (l *LogsOptions) runLogs(cmd *cobra.Command, args []string) error {
+	if err := l.complete(cmd); err != nil {
+		return err
+	}
+	clientset, err := l.clientset()
+	if err != nil {
+		return err
+	}
+
+	return l.streamRhinoJobLogs(context.TODO(), clientset, cmd.OutOrStdout())
+}
+
+// listRhinoJobPods finds the pods of the launcher and workers Jobs owned by a RHINO job,
+// with the launcher first and the workers sorted by rank
+func listRhinoJobPods(ctx context.Context, clientset kubernetes.Interface, namespace string, rhinojobName string) ([]corev1.Pod, error) {
+	jobList, err := clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var jobNames []string
+	for _, job := range jobList.Items {
+		for _, owner := range job.OwnerReferences {
+			if owner.Kind == "RhinoJob" && owner.Name == rhinojobName {
+				jobNames = append(jobNames, job.Name)
+				break
+			}
+		}
+	}
+	if len(jobNames) == 0 {
+		// Fall back to the names the operator gives to the Jobs
+		jobNames = []string{rhinojobName + launcherJobSuffix, rhinojobName + workersJobSuffix}
+	}
+
+	requirement, err := labels.NewRequirement(jobNameLabel, selection.In, jobNames)
+	if err != nil {
+		return nil, err
+	}
+	podList, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.NewSelector().Add(*requirement).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pods := podList.Items
+	sort.SliceStable(pods, func(i, j int) bool {
+		return podSortKey(pods[i]) < podSortKey(pods[j])
+	})
+	return pods, nil
+}
+
+// podDisplayName names a pod of a RHINO job by its role: "launcher", or "worker-<rank>"
+func podDisplayName(pod corev1.Pod) string {
+	jobName := pod.Labels[jobNameLabel]
+	switch {
+	case strings.HasSuffix(jobName, launcherJobSuffix):
+		return "launcher"
+	case strings.HasSuffix(jobName, workersJobSuffix):
+		if index, ok := pod.Annotations[jobCompletionIndexAnnotation]; ok {
+			return "worker-" + index
+		}
+	}
+	return pod.Name
+}
+
+func podSortKey(pod corev1.Pod) string {
+	if strings.HasSuffix(pod.Labels[jobNameLabel], launcherJobSuffix) {
+		return "0"
+	}
+	if index, err := strconv.Atoi(pod.Annotations[jobCompletionIndexAnnotation]); err == nil {
+		return fmt.Sprintf("1%09d", index)
+	}
+	return "2" + pod.Name
+}
+
+// streamRhinoJobLogs copies the logs of all the pods of the RHINO job to out, concurrently,
+// prefixing each line with the name of the pod it comes from
+func (l *LogsOptions) streamRhinoJobLogs(ctx context.Context, clientset kubernetes.Interface, out io.Writer) error {
+	pods, err := listRhinoJobPods(ctx, clientset, l.namespace, l.rhinojobName)
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("no pods found for RhinoJob %s, it may not exist or still be pending", l.rhinojobName)
+	}
+
+	logOptions := &corev1.PodLogOptions{Follow: l.follow}
+	if l.since > 0 {
+		sinceSeconds := int64(l.since.Seconds())
+		logOptions.SinceSeconds = &sinceSeconds
+	}
+	if l.tail >= 0 {
+		logOptions.TailLines = &l.tail
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, len(pods))
+	for i, pod := range pods {
+		wg.Add(1)
+		go func(i int, pod corev1.Pod) {
+			defer wg.Done()
+			errs[i] = streamPodLogs(ctx, clientset, pod, logOptions, out, &mu)
+		}(i, pod)
+	}
+	wg.Wait()
+
+	var messages []string
+	for i, err := range errs {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("%s: %s", podDisplayName(pods[i]), err.Error()))
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("failed to get logs of some pods:\n%s", strings.Join(messages, "\n"))
+	}
+	return nil
+}
+
+func streamPodLogs(ctx context.Context, clientset kubernetes.Interface, pod corev1.Pod,
+	logOptions *corev1.PodLogOptions, out io.Writer, mu *sync.Mutex) error {
+	logReader, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOptions).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer logReader.Close()
+
+	prefix := "[" + podDisplayName(pod) + "] "
+	reader := bufio.NewReader(logReader)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			mu.Lock()
+			_, writeErr := io.WriteString(out, prefix+line)
+			mu.Unlock()
+			if writeErr != nil {
+				return writeErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
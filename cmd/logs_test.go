@@ -0,0 +1,107 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestJob returns a Job owned by the RHINO job, as the operator creates it
+func newTestJob(rhinojobName string, suffix string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rhinojobName + suffix,
+			Namespace: testFuncRunNamespace,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "openrhino.org/v1alpha1",
+				Kind:       "RhinoJob",
+				Name:       rhinojobName,
+			}},
+		},
+	}
+}
+
+// newTestPod returns a pod of the Job. A negative index means the pod is not indexed.
+func newTestPod(jobName string, index int, node string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName + "-abcde",
+			Namespace: testFuncRunNamespace,
+			Labels:    map[string]string{jobNameLabel: jobName},
+		},
+		Spec:   corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if index >= 0 {
+		pod.Name = fmt.Sprintf("%s-%d-abcde", jobName, index)
+		pod.Annotations = map[string]string{jobCompletionIndexAnnotation: fmt.Sprint(index)}
+	}
+	return pod
+}
+
+// newTestClientset returns a fake clientset holding the Jobs and pods of a RHINO job with 2 workers
+func newTestClientset(rhinojobName string, objects ...runtime.Object) *fake.Clientset {
+	objects = append(objects,
+		newTestJob(rhinojobName, launcherJobSuffix),
+		newTestJob(rhinojobName, workersJobSuffix),
+		newTestPod(rhinojobName+workersJobSuffix, 1, "node-b"),
+		newTestPod(rhinojobName+launcherJobSuffix, -1, "node-a"),
+		newTestPod(rhinojobName+workersJobSuffix, 0, "node-a"),
+		newTestPod("other-job"+workersJobSuffix, 0, "node-a"),
+	)
+	return fake.NewSimpleClientset(objects...)
+}
+
+func TestListRhinoJobPods(t *testing.T) {
+	clientset := newTestClientset("test-logs")
+	pods, err := listRhinoJobPods(context.TODO(), clientset, testFuncRunNamespace, "test-logs")
+	assert.Equal(t, nil, err, "test logs failed: %s", errorMessage(err))
+
+	var names []string
+	for _, pod := range pods {
+		names = append(names, podDisplayName(pod))
+	}
+	assert.Equal(t, []string{"launcher", "worker-0", "worker-1"}, names)
+}
+
+func TestStreamRhinoJobLogs(t *testing.T) {
+	clientset := newTestClientset("test-logs")
+	logsOpts := &LogsOptions{rhinojobName: "test-logs", KubeOptions: KubeOptions{namespace: testFuncRunNamespace}, tail: -1}
+	out := new(bytes.Buffer)
+	err := logsOpts.streamRhinoJobLogs(context.TODO(), clientset, out)
+	assert.Equal(t, nil, err, "test logs failed: %s", errorMessage(err))
+
+	// the fake clientset returns "fake logs" for every pod
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.ElementsMatch(t, []string{"[launcher] fake logs", "[worker-0] fake logs", "[worker-1] fake logs"}, lines)
+}
+
+func TestStreamRhinoJobLogsWithoutPods(t *testing.T) {
+	logsOpts := &LogsOptions{rhinojobName: "test-logs", KubeOptions: KubeOptions{namespace: testFuncRunNamespace}, tail: -1}
+	err := logsOpts.streamRhinoJobLogs(context.TODO(), fake.NewSimpleClientset(), new(bytes.Buffer))
+	assert.Equal(t, fmt.Errorf("no pods found for RhinoJob test-logs, it may not exist or still be pending"), err)
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+)
+
+// The user config holds named profiles, each of them a set of defaults for the cluster
+// and the registry to use. The profile is chosen with the global --profile flag, or
+// else the current profile set by `rhino config use-profile` is used.
+const (
+	userConfigEnv      = "RHINO_CONFIG"
+	profileFlag        = "profile"
+	defaultProfileName = "default"
+)
+
+type UserConfig struct {
+	CurrentProfile string              `json:"currentProfile,omitempty"`
+	Profiles       map[string]*Profile `json:"profiles,omitempty"`
+}
+
+type Profile struct {
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	Context    string `json:"context,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Registry   string `json:"registry,omitempty"`
+	NFSServer  string `json:"nfsServer,omitempty"`
+	TTL        *int   `json:"ttl,omitempty"`
+}
+
+// userConfigPath returns the path of the user config, ~/.rhino/config.yaml unless $RHINO_CONFIG is set
+func userConfigPath() (string, error) {
+	if path := os.Getenv(userConfigEnv); path != "" {
+		return path, nil
+	}
+	home := homedir.HomeDir()
+	if home == "" {
+		return "", fmt.Errorf("home directory not found, please set $%s to the path of the rhino config file", userConfigEnv)
+	}
+	return filepath.Join(home, ".rhino", "config.yaml"), nil
+}
+
+// loadUserConfig reads the user config, which is empty if the file does not exist
+func loadUserConfig() (*UserConfig, string, error) {
+	path, err := userConfigPath()
+	if err != nil {
+		return nil, "", err
+	}
+	config := &UserConfig{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, path, nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %s", path, err.Error())
+	}
+	return config, path, nil
+}
+
+func (c *UserConfig) save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// profileName returns the profile chosen by the global --profile flag, if the command has it
+func profileName(cmd *cobra.Command) string {
+	if flag := cmd.Flag(profileFlag); flag != nil {
+		return flag.Value.String()
+	}
+	return ""
+}
+
+// loadProfile returns the profile chosen for the command, or an empty profile if no profile is used
+func loadProfile(cmd *cobra.Command) (*Profile, error) {
+	config, path, err := loadUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	name := profileName(cmd)
+	if name == "" {
+		name = config.CurrentProfile
+	}
+	if name == "" {
+		return &Profile{}, nil
+	}
+	profile, exist := config.Profiles[name]
+	if !exist {
+		return nil, fmt.Errorf("profile %q not found in %s", name, path)
+	}
+	return profile, nil
+}
+
+// imageWithRegistry prefixes an image name with the registry of the profile
+func (p *Profile) imageWithRegistry(image string) string {
+	if p.Registry == "" {
+		return image
+	}
+	return strings.TrimSuffix(p.Registry, "/") + "/" + image
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// The project descriptor is written by `rhino create` into the project directory.
+// The build, run and docker-run commands read their defaults from it, and the
+// options given on the command line take priority over it.
+const (
+	projectFileName = "rhino.yaml"
+	projectVersion  = 1
+)
+
+// ProjectConfig is the schema of the project descriptor
+type ProjectConfig struct {
+	Version   int                    `json:"version"`
+	Name      string                 `json:"name"`
+	Image     string                 `json:"image,omitempty"`
+	Build     ProjectBuildConfig     `json:"build,omitempty"`
+	Run       ProjectRunConfig       `json:"run,omitempty"`
+	DockerRun ProjectDockerRunConfig `json:"dockerRun,omitempty"`
+}
+
+type ProjectBuildConfig struct {
+	// Relative path of the makefile
+	Makefile string `json:"makefile,omitempty"`
+	// Arguments passed to make, e.g. ["-j", "all"]
+	MakeArgs []string `json:"makeArgs,omitempty"`
+	// Name of the executable built by the makefile, "mpi-func" by default.
+	// With several executables, the one run by default.
+	Exec string `json:"exec,omitempty"`
+	// Names of all the executables built by the makefile, to package them into one image
+	Execs []string `json:"execs,omitempty"`
+	// Base images to compile the MPI program in and to run it, in place of the default ones
+	BuilderImage string `json:"builderImage,omitempty"`
+	RuntimeImage string `json:"runtimeImage,omitempty"`
+}
+
+type ProjectRunConfig struct {
+	NP     *int     `json:"np,omitempty"`
+	TTL    *int     `json:"ttl,omitempty"`
+	Server string   `json:"server,omitempty"`
+	Dir    string   `json:"dir,omitempty"`
+	Args   []string `json:"args,omitempty"`
+}
+
+type ProjectDockerRunConfig struct {
+	NP     *int     `json:"np,omitempty"`
+	Volume string   `json:"volume,omitempty"`
+	Args   []string `json:"args,omitempty"`
+}
+
+// newProjectConfig returns the descriptor written into a new project
+func newProjectConfig(name string) *ProjectConfig {
+	np, ttl := 1, 600
+	return &ProjectConfig{
+		Version: projectVersion,
+		Name:    name,
+		Image:   toDNS1123Label(name, maxRhinoJobNameLength) + ":latest",
+		Build:   ProjectBuildConfig{Makefile: "./src/Makefile", Exec: defaultExecName},
+		Run:     ProjectRunConfig{NP: &np, TTL: &ttl},
+	}
+}
+
+// loadProjectConfig reads the project descriptor in dir.
+// It returns nil without an error if dir has no project descriptor.
+func loadProjectConfig(dir string) (*ProjectConfig, error) {
+	path := filepath.Join(dir, projectFileName)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var config ProjectConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		// Strip the decoder details, e.g. `error unmarshaling JSON: while decoding JSON: json: unknown field "imgae"`
+		message := err.Error()
+		for _, prefix := range []string{"error unmarshaling JSON: ", "while decoding JSON: ", "json: "} {
+			message = strings.TrimPrefix(message, prefix)
+		}
+		return nil, fmt.Errorf("invalid %s: %s", path, message)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", path, err.Error())
+	}
+	return &config, nil
+}
+
+func (p *ProjectConfig) validate() error {
+	switch {
+	case p.Version == 0:
+		return fmt.Errorf("version is missing, it should be %d", projectVersion)
+	case p.Version != projectVersion:
+		return fmt.Errorf("unsupported version %d, this version of rhino supports version %d", p.Version, projectVersion)
+	case p.Build.Exec != "" && !validExecName.MatchString(p.Build.Exec):
+		return fmt.Errorf("build.exec must be the file name of the executable built by the makefile, such as %s", defaultExecName)
+	case !allValidExecNames(p.Build.Execs):
+		return fmt.Errorf("build.execs must be the file names of the executables built by the makefile")
+	case p.Build.Exec != "" && len(p.Build.Execs) > 0 && !containsString(p.Build.Execs, p.Build.Exec):
+		return fmt.Errorf("build.exec must be one of build.execs")
+	case p.Run.NP != nil && *p.Run.NP < 1:
+		return fmt.Errorf("run.np must be greater than 0")
+	case p.Run.TTL != nil && *p.Run.TTL < 0:
+		return fmt.Errorf("run.ttl must be greater than or equal to 0")
+	case (p.Run.Server == "") != (p.Run.Dir == ""):
+		return fmt.Errorf("run.server and run.dir must be set together")
+	case p.DockerRun.NP != nil && *p.DockerRun.NP < 1:
+		return fmt.Errorf("dockerRun.np must be greater than 0")
+	case p.DockerRun.Volume != "" && len(strings.SplitN(p.DockerRun.Volume, ":", 2)) != 2:
+		return fmt.Errorf("dockerRun.volume should be in the format <host-path>:<container-path>")
+	}
+	return nil
+}
+
+func allValidExecNames(names []string) bool {
+	for _, name := range names {
+		if !validExecName.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// writeProjectConfig writes the project descriptor into dir
+func writeProjectConfig(dir string, config *ProjectConfig) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	header := "# RHINO project descriptor, read by `rhino build`, `rhino run` and `rhino docker-run`.\n" +
+		"# Options given on the command line take priority over the ones in this file.\n"
+	return os.WriteFile(filepath.Join(dir, projectFileName), append([]byte(header), data...), 0644)
+}
+
+// splitImageArgs splits the positional arguments of run and docker-run into the image and the
+// app args. The image may be left out before "--" when the project descriptor provides it.
+func splitImageArgs(argsLenAtDash int, args []string, config *ProjectConfig) (string, []string, error) {
+	if len(args) > 0 && argsLenAtDash != 0 {
+		return args[0], args[1:], nil
+	}
+	if config == nil || config.Image == "" {
+		return "", nil, fmt.Errorf("please provide the image, or run in a project directory with an image in %s", projectFileName)
+	}
+	return config.Image, args, nil
+}
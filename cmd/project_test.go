@@ -0,0 +1,190 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+// chdirTemp changes the work directory to a new temporary directory for the rest of the test
+func chdirTemp(t *testing.T) string {
+	cwd, err := os.Getwd()
+	assert.Equal(t, nil, err, "failed to get the work directory: %s", errorMessage(err))
+	dir := t.TempDir()
+	assert.Equal(t, nil, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(cwd) })
+	return dir
+}
+
+func TestLoadProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	project, err := loadProjectConfig(dir)
+	assert.Equal(t, nil, err, "a missing project descriptor should not be an error")
+	assert.Nil(t, project)
+
+	err = writeProjectConfig(dir, newProjectConfig("hello"))
+	assert.Equal(t, nil, err, "test project failed: %s", errorMessage(err))
+	project, err = loadProjectConfig(dir)
+	assert.Equal(t, nil, err, "test project failed: %s", errorMessage(err))
+	assert.Equal(t, newProjectConfig("hello"), project)
+}
+
+func TestLoadInvalidProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, projectFileName)
+	for content, expected := range map[string]string{
+		"name: hello":                                    "version is missing, it should be 1",
+		"version: 2":                                     "unsupported version 2, this version of rhino supports version 1",
+		"version: 1\nrun:\n  np: 0":                      "run.np must be greater than 0",
+		"version: 1\nrun:\n  ttl: -1":                    "run.ttl must be greater than or equal to 0",
+		"version: 1\nrun:\n  server: 10.0.0.7":           "run.server and run.dir must be set together",
+		"version: 1\ndockerRun:\n  volume: /a":           "dockerRun.volume should be in the format <host-path>:<container-path>",
+		"version: 1\nimgae: hello:v1":                    `unknown field "imgae"`,
+		"version: 1\nbuild:\n  exec: bin/app":            "build.exec must be the file name of the executable built by the makefile, such as mpi-func",
+		"version: 1\nbuild:\n  execs: [a, b c]":          "build.execs must be the file names of the executables built by the makefile",
+		"version: 1\nbuild:\n  exec: c\n  execs: [a, b]": "build.exec must be one of build.execs",
+	} {
+		err := os.WriteFile(path, []byte(content), 0644)
+		assert.Equal(t, nil, err, "test project failed: %s", errorMessage(err))
+		_, err = loadProjectConfig(dir)
+		assert.Equal(t, fmt.Errorf("invalid %s: %s", path, expected), err)
+	}
+}
+
+// check if run takes its defaults from the project descriptor, and if the flags take priority
+func TestRunWithProjectConfig(t *testing.T) {
+	kubeconfig := writeTestKubeconfig(t, testFuncRunNamespace)
+	dir := chdirTemp(t)
+	np, ttl := 4, 100
+	project := newProjectConfig("test-project")
+	project.Run = ProjectRunConfig{NP: &np, TTL: &ttl, Server: "10.0.0.7", Dir: "/mnt", Args: []string{"a", "b"}}
+	project.Build.Exec = "lulesh2.0"
+	assert.Equal(t, nil, writeProjectConfig(dir, project))
+
+	runDryRun := func(args ...string) rhinojob.RhinoJob {
+		rootCmd := NewRootCommand()
+		out := new(bytes.Buffer)
+		rootCmd.SetOut(out)
+		rootCmd.SetArgs(append([]string{"run", "--kubeconfig", kubeconfig, "--dry-run"}, args...))
+		err := rootCmd.Execute()
+		assert.Equal(t, nil, err, "test run with project failed: %s", errorMessage(err))
+		var rj rhinojob.RhinoJob
+		assert.Equal(t, nil, yaml.Unmarshal(out.Bytes(), &rj))
+		return rj
+	}
+
+	rj := runDryRun()
+	assert.Equal(t, "test-project:latest", rj.Spec.Image)
+	assert.Equal(t, int32(4), *rj.Spec.Parallelism)
+	assert.Equal(t, int32(100), *rj.Spec.TTL)
+	assert.Equal(t, "10.0.0.7", rj.Spec.DataServer)
+	assert.Equal(t, []string{"a", "b"}, rj.Spec.AppArgs)
+	assert.Equal(t, "./lulesh2.0", rj.Spec.AppExec)
+
+	rj = runDryRun("--np", "8", "--", "c")
+	assert.Equal(t, "test-project:latest", rj.Spec.Image)
+	assert.Equal(t, int32(8), *rj.Spec.Parallelism)
+	assert.Equal(t, int32(100), *rj.Spec.TTL)
+	assert.Equal(t, []string{"c"}, rj.Spec.AppArgs)
+
+	rj = runDryRun("foo/other:v2", "--ttl", "0")
+	assert.Equal(t, "foo/other:v2", rj.Spec.Image)
+	assert.Equal(t, int32(0), *rj.Spec.TTL)
+	assert.Equal(t, []string{"a", "b"}, rj.Spec.AppArgs)
+}
+
+// check if build takes the image and the makefile from the project descriptor
+func TestBuildWithProjectConfig(t *testing.T) {
+	dir := chdirTemp(t)
+	project := newProjectConfig("test-project")
+	project.Image = "test_project:v1"
+	assert.Equal(t, nil, writeProjectConfig(dir, project))
+
+	rootCmd := NewRootCommand()
+	rootCmd.SetArgs([]string{"build"})
+	err := rootCmd.Execute()
+	assert.Equal(t, fmt.Errorf("image name can only contain a~z, 0~9 and -"), err, "test failed: invalid image name in the project descriptor not reported")
+
+	buildOpts := &BuildOptions{}
+	buildCmd := NewBuildCommand()
+	project.Image = "test-project:v1"
+	project.Build.MakeArgs = []string{"-j", "all"}
+	assert.Equal(t, nil, writeProjectConfig(dir, project))
+	err = buildOpts.validateArgs(buildCmd, nil)
+	assert.Equal(t, nil, err, "test build with project failed: %s", errorMessage(err))
+	assert.Equal(t, "test-project:v1", buildOpts.image)
+	assert.Equal(t, "./src/Makefile", buildOpts.file)
+	assert.Equal(t, []string{"-j", "all"}, buildOpts.makeArgs)
+	assert.Equal(t, builderBaseImage, buildOpts.builderImage)
+	assert.Equal(t, runtimeBaseImage, buildOpts.runtimeImage)
+
+	project.Build.BuilderImage = "foo/mpich-builder:v4.1"
+	assert.Equal(t, nil, writeProjectConfig(dir, project))
+	buildOpts = &BuildOptions{runtimeImage: "foo/mpich-run:v4.1"}
+	buildCmd.Flags().Set("runtime-image", "foo/mpich-run:v4.1")
+	err = buildOpts.validateArgs(buildCmd, nil)
+	assert.Equal(t, nil, err, "test build with project failed: %s", errorMessage(err))
+	assert.Equal(t, "foo/mpich-builder:v4.1", buildOpts.builderImage)
+	assert.Equal(t, "foo/mpich-run:v4.1", buildOpts.runtimeImage)
+	assert.Equal(t, []string{"mpi-func"}, buildOpts.execs)
+
+	// the default executable of the project is packaged first
+	project.Build.Exec = "solver"
+	project.Build.Execs = []string{"preprocess", "solver", "bench"}
+	assert.Equal(t, nil, writeProjectConfig(dir, project))
+	buildOpts = &BuildOptions{}
+	err = buildOpts.validateArgs(buildCmd, nil)
+	assert.Equal(t, nil, err, "test build with project failed: %s", errorMessage(err))
+	assert.Equal(t, []string{"solver", "preprocess", "bench"}, buildOpts.execs)
+}
+
+func TestResolveExec(t *testing.T) {
+	labels := map[string]string{execLabel: "/app/solver", execsLabel: "/app/solver,/app/preprocess,/app/bench"}
+	project := newProjectConfig("test-project")
+	project.Build.Exec = ""
+	project.Build.Execs = []string{"solver", "preprocess"}
+	for _, test := range []struct {
+		labels   map[string]string
+		project  *ProjectConfig
+		name     string
+		expected string
+	}{
+		{labels, project, "", "/app/solver"},
+		{labels, nil, "bench", "/app/bench"},
+		{labels, project, "mesh", "the image has no executable mesh, it has: solver, preprocess, bench"},
+		{map[string]string{execLabel: "/app/mpi-func"}, nil, "", "/app/mpi-func"},
+		{nil, project, "", "./solver"},
+		{nil, project, "preprocess", "./preprocess"},
+		{nil, project, "bench", "the project has no executable bench, it has: solver, preprocess. Declare it in build.execs in rhino.yaml"},
+		{nil, nil, "bench", "./bench"},
+		{nil, nil, "", "./mpi-func"},
+		{nil, nil, "../bench", `invalid executable name (--exec) "../bench", it must be a file name such as mpi-func`},
+	} {
+		exec, err := resolveExec(test.labels, test.project, test.name, "./")
+		if err != nil {
+			exec = err.Error()
+		}
+		assert.Equal(t, test.expected, exec)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+// The annotation recording the RHINO job a job was rerun from
+const rerunOfAnnotation = "openrhino.org/rerun-of"
+
+type RerunOptions struct {
+	sourceName string
+	jobName    string
+	image      string
+	parallel   int
+	timeToLive int
+	dataServer string
+	dataPath   string
+	appArgs    []string
+	KubeOptions
+}
+
+func NewRerunCommand() *cobra.Command {
+	rerunOpts := &RerunOptions{}
+	rerunCmd := &cobra.Command{
+		Use:   "rerun [name] [-- args]",
+		Short: "Run an existing RHINO job again",
+		Long:  "\nCreate a new RHINO job from the spec of an existing one, with a new name. The options given on the command line override the ones of the existing job, and the arguments after -- replace its arguments.",
+		Example: `  rhino rerun hello-x7k2p
+  rhino rerun matmul --np 8 --ttl 1200 -- --size 4096
+  rhino rerun matmul --name matmul-retry --image foo/matmul:v2.2`,
+		Args: rerunOpts.argsCheck,
+		RunE: rerunOpts.runRerun,
+	}
+
+	rerunCmd.Flags().StringVar(&rerunOpts.jobName, "name", "", "the name of the new RHINO job. By default, a unique name is generated from the name of the existing job")
+	rerunCmd.Flags().StringVar(&rerunOpts.image, "image", "", "the image of the new RHINO job")
+	rerunCmd.Flags().IntVar(&rerunOpts.parallel, "np", 1, "the number of MPI processes")
+	rerunCmd.Flags().IntVarP(&rerunOpts.timeToLive, "ttl", "t", 600, "Time To Live (seconds). The RHINO job will be deleted after this time, whether it is completed or not.")
+	rerunCmd.Flags().StringVar(&rerunOpts.dataServer, "server", "", "IP address of an NFS server")
+	rerunCmd.Flags().StringVar(&rerunOpts.dataPath, "dir", "", "a directory in the NFS server, to store data and shared with all the MPI processes")
+	rerunOpts.addKubeFlags(rerunCmd.Flags())
+
+	return rerunCmd
+}
+
+func (r *RerunOptions) argsCheck(cmd *cobra.Command, args []string) error {
+	dash := cmd.ArgsLenAtDash()
+	names := args
+	if dash >= 0 {
+		names, r.appArgs = args[:dash], args[dash:]
+	}
+	if len(names) != 1 {
+		return fmt.Errorf("exactly one RHINO job name is required, and the arguments of the new job must follow --")
+	}
+	r.sourceName = names[0]
+	return nil
+}
+
+func (r *RerunOptions) runRerun(cmd *cobra.Command, args []string) error {
+	if r.jobName != "" {
+		if errs := validation.IsDNS1123Label(r.jobName); len(errs) > 0 {
+			return fmt.Errorf("invalid RHINO job name (--name) %q: %s", r.jobName, strings.Join(errs, ", "))
+		}
+		if len(r.jobName) > maxRhinoJobNameLength {
+			return fmt.Errorf("the RHINO job name (--name) cannot exceed %d characters", maxRhinoJobNameLength)
+		}
+	}
+	if cmd.Flags().Changed("np") && r.parallel < 1 {
+		return fmt.Errorf("the number of MPI processes (--np) must be greater than 0")
+	}
+	if cmd.Flags().Changed("ttl") && r.timeToLive < 0 {
+		return fmt.Errorf("the time to live (--ttl) must be greater than or equal to 0")
+	}
+	if err := r.complete(cmd); err != nil {
+		return err
+	}
+	dynamicClient, err := r.rhinoJobClient()
+	if err != nil {
+		return err
+	}
+	return r.rerunRhinoJob(context.TODO(), dynamicClient, cmd.Flags(), cmd.ArgsLenAtDash() >= 0,
+		cmd.OutOrStdout(), cmd.ErrOrStderr())
+}
+
+// rerunRhinoJob creates a copy of the existing RHINO job with the overrides, after validating it
+func (r *RerunOptions) rerunRhinoJob(ctx context.Context, client dynamic.Interface, flags *pflag.FlagSet,
+	argsGiven bool, out io.Writer, warnings io.Writer) error {
+	gvr := r.rhinoJobResource()
+	rjClient := client.Resource(gvr).Namespace(r.namespace)
+	source, err := rjClient.Get(ctx, r.sourceName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	sourceJob, err := rhinoJobFromUnstructured(source)
+	if err != nil {
+		return err
+	}
+	rj := r.newRhinoJob(&sourceJob, flags, argsGiven)
+	// An override may clear the NFS server or the directory of the existing job, but not only one of them
+	if (rj.Spec.DataServer == "") != (rj.Spec.DataPath == "") {
+		return fmt.Errorf("the NFS server (--server) and directory (--dir) must be set together")
+	}
+
+	obj, err := rhinoJobToUnstructured(rj)
+	if err != nil {
+		return err
+	}
+	if obj, err = convertRhinoJob(obj, gvr); err != nil {
+		return err
+	}
+	if err := validateRhinoJob(obj, r.rhinoJobSchema(client, gvr, warnings)); err != nil {
+		return err
+	}
+	created, err := rjClient.Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "RhinoJob %s created from %s\n", created.GetName(), r.sourceName)
+	return nil
+}
+
+// newRhinoJob copies the spec and labels of the source job, applying the options given on the command line
+func (r *RerunOptions) newRhinoJob(source *rhinojob.RhinoJob, flags *pflag.FlagSet, argsGiven bool) *rhinojob.RhinoJob {
+	rj := &rhinojob.RhinoJob{
+		TypeMeta: metav1.TypeMeta{APIVersion: rhinojob.GroupVersion.String(), Kind: "RhinoJob"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   r.namespace,
+			Labels:      source.Labels,
+			Annotations: map[string]string{rerunOfAnnotation: source.Name},
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+	if r.jobName != "" {
+		rj.Name = r.jobName
+	} else {
+		rj.GenerateName = rerunGenerateName(source)
+	}
+	if flags.Changed("image") {
+		rj.Spec.Image = r.image
+	}
+	if flags.Changed("np") {
+		parallelism := int32(r.parallel)
+		rj.Spec.Parallelism = &parallelism
+	}
+	if flags.Changed("ttl") {
+		ttl := int32(r.timeToLive)
+		rj.Spec.TTL = &ttl
+	}
+	if flags.Changed("server") {
+		rj.Spec.DataServer = r.dataServer
+	}
+	if flags.Changed("dir") {
+		rj.Spec.DataPath = r.dataPath
+	}
+	if argsGiven {
+		rj.Spec.AppArgs = append([]string{}, r.appArgs...)
+	}
+	return rj
+}
+
+// rerunGenerateName returns the prefix of the generated name of a rerun: the one of the source job
+// if its name was generated too, so that reruns of reruns do not pile up suffixes, or else its name
+func rerunGenerateName(source *rhinojob.RhinoJob) string {
+	prefix := source.GenerateName
+	if prefix == "" {
+		prefix = toDNS1123Label(source.Name, maxRhinoJobNameLength-generatedNameSuffixLength-1) + "-"
+	}
+	return prefix
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// newTestRerunFlags returns the override flags of rhino rerun, parsed into rerunOpts
+func newTestRerunFlags(t *testing.T, rerunOpts *RerunOptions, args ...string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("rerun", pflag.ContinueOnError)
+	flags.StringVar(&rerunOpts.image, "image", "", "")
+	flags.IntVar(&rerunOpts.parallel, "np", 1, "")
+	flags.IntVar(&rerunOpts.timeToLive, "ttl", 600, "")
+	flags.StringVar(&rerunOpts.dataServer, "server", "", "")
+	flags.StringVar(&rerunOpts.dataPath, "dir", "", "")
+	assert.Equal(t, nil, flags.Parse(args))
+	return flags
+}
+
+func newTestRerunSource(t *testing.T) *unstructured.Unstructured {
+	runOpts := &RunOptions{parallel: 4, timeToLive: 300, funcName: "matmul", jobName: "matmul-a1b2c",
+		dataServer: "10.0.0.7", dataPath: "/mnt"}
+	obj, err := rhinoJobToUnstructured(runOpts.newRhinoJob([]string{"foo/matmul:v1", "--size", "1024"}))
+	assert.Equal(t, nil, err, "test rerun failed: %s", errorMessage(err))
+	obj.SetNamespace(testFuncRunNamespace)
+	obj.SetGenerateName("matmul-")
+	obj.SetResourceVersion("7")
+	unstructured.SetNestedField(obj.Object, string(rhinojob.Completed), "status", "jobStatus")
+	return obj
+}
+
+func TestRerunRhinoJob(t *testing.T) {
+	useTestUserConfig(t)
+	source := newTestRerunSource(t)
+	client := newFakeDynamicClient(source)
+	rerunOpts := &RerunOptions{sourceName: "matmul-a1b2c", jobName: "matmul-retry", appArgs: []string{"--size", "4096"},
+		KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	flags := newTestRerunFlags(t, rerunOpts, "--np", "8", "--dir", "/data")
+	out := new(bytes.Buffer)
+	err := rerunOpts.rerunRhinoJob(context.TODO(), client, flags, true, out, new(bytes.Buffer))
+	assert.Equal(t, nil, err, "test rerun failed: %s", errorMessage(err))
+	assert.Equal(t, "RhinoJob matmul-retry created from matmul-a1b2c\n", out.String())
+
+	obj, err := client.Tracker().Get(RhinoJobGVR, testFuncRunNamespace, "matmul-retry")
+	assert.Equal(t, nil, err, "test rerun failed: %s", errorMessage(err))
+	rj, err := rhinoJobFromUnstructured(obj.(*unstructured.Unstructured))
+	assert.Equal(t, nil, err, "test rerun failed: %s", errorMessage(err))
+	assert.Equal(t, "foo/matmul:v1", rj.Spec.Image)
+	assert.Equal(t, int32(8), *rj.Spec.Parallelism)
+	assert.Equal(t, int32(300), *rj.Spec.TTL)
+	assert.Equal(t, "10.0.0.7", rj.Spec.DataServer)
+	assert.Equal(t, "/data", rj.Spec.DataPath)
+	assert.Equal(t, []string{"--size", "4096"}, rj.Spec.AppArgs)
+	assert.Equal(t, map[string]string{rerunOfAnnotation: "matmul-a1b2c"}, rj.Annotations)
+	assert.Equal(t, rhinojob.JobStatus(""), rj.Status.JobStatus)
+
+	// an invalid override is reported before anything is created
+	rerunOpts = &RerunOptions{sourceName: "matmul-a1b2c", jobName: "matmul-invalid",
+		KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	flags = newTestRerunFlags(t, rerunOpts, "--server", "10.0.0.")
+	err = rerunOpts.rerunRhinoJob(context.TODO(), client, flags, false, new(bytes.Buffer), new(bytes.Buffer))
+	assert.Contains(t, errorMessage(err), `spec.dataServer: Invalid value: "10.0.0.": must be an IP address or a host name`)
+
+	flags = newTestRerunFlags(t, rerunOpts, "--server", "")
+	err = rerunOpts.rerunRhinoJob(context.TODO(), client, flags, false, new(bytes.Buffer), new(bytes.Buffer))
+	assert.Equal(t, "the NFS server (--server) and directory (--dir) must be set together", errorMessage(err))
+
+	flags = newTestRerunFlags(t, rerunOpts, "--server", "", "--dir", "")
+	rerunOpts.jobName = "matmul-local"
+	err = rerunOpts.rerunRhinoJob(context.TODO(), client, flags, false, new(bytes.Buffer), new(bytes.Buffer))
+	assert.Equal(t, nil, err, "test rerun failed: %s", errorMessage(err))
+	_, err = client.Tracker().Get(RhinoJobGVR, testFuncRunNamespace, "matmul-invalid")
+	assert.Equal(t, `rhinojobs.openrhino.org "matmul-invalid" not found`, errorMessage(err))
+
+	rerunOpts.sourceName = "not-found"
+	err = rerunOpts.rerunRhinoJob(context.TODO(), client, flags, false, new(bytes.Buffer), new(bytes.Buffer))
+	assert.Equal(t, `rhinojobs.openrhino.org "not-found" not found`, errorMessage(err))
+}
+
+func TestRerunGenerateName(t *testing.T) {
+	source, err := rhinoJobFromUnstructured(newTestRerunSource(t))
+	assert.Equal(t, nil, err, "test rerun failed: %s", errorMessage(err))
+	rerunOpts := &RerunOptions{KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	rj := rerunOpts.newRhinoJob(&source, newTestRerunFlags(t, rerunOpts), false)
+	assert.Equal(t, "", rj.Name)
+	assert.Equal(t, "matmul-", rj.GenerateName)
+	assert.Equal(t, []string{"--size", "1024"}, rj.Spec.AppArgs)
+
+	source.GenerateName = ""
+	source.Name = "My_Job"
+	assert.Equal(t, "my-job-", rerunGenerateName(&source))
+}
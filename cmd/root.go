@@ -24,6 +24,7 @@ func NewRootCommand() *cobra.Command {
 		Use:   "rhino",
 		Short: "\nRHINO-CLI - Manage your OpenRHINO functions and jobs",
 	}
+	rootCmd.PersistentFlags().String(profileFlag, "", "the profile in ~/.rhino/config.yaml to use, instead of the current profile")
 
 	rootCmd.AddCommand(NewCreateCommand())
 	rootCmd.AddCommand(NewBuildCommand())
@@ -31,6 +32,17 @@ func NewRootCommand() *cobra.Command {
 	rootCmd.AddCommand(NewRunCommand())
 	rootCmd.AddCommand(NewListCommand())
 	rootCmd.AddCommand(NewDockerRunCommand())
+	rootCmd.AddCommand(NewWaitCommand())
+	rootCmd.AddCommand(NewLogsCommand())
+	rootCmd.AddCommand(NewDescribeCommand())
+	rootCmd.AddCommand(NewConfigCommand())
+	rootCmd.AddCommand(NewDoctorCommand())
+	rootCmd.AddCommand(NewInstallCommand())
+	rootCmd.AddCommand(NewUninstallCommand())
+	rootCmd.AddCommand(NewVersionCommand())
+	rootCmd.AddCommand(NewApplyCommand())
+	rootCmd.AddCommand(NewExportCommand())
+	rootCmd.AddCommand(NewRerunCommand())
 
 	return rootCmd
 }
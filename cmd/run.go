@@ -17,18 +17,17 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"path/filepath"
-	"strconv"
+	"io"
+	"strings"
+	"time"
 
 	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/util/homedir"
 )
 
 type RunOptions struct {
@@ -37,129 +36,290 @@ type RunOptions struct {
 	dataPath   string
 	dataServer string
 	funcName   string
+	exec       string
+	appExec    string
+	jobName    string
+	dryRun     string
+	output     string
+	wait       bool
+	follow     bool
+	timeout    time.Duration
 
-	kubeconfig string
-	namespace  string
+	KubeOptions
 }
 
 func NewRunCommand() *cobra.Command {
 	runOpts := &RunOptions{}
 	runCmd := &cobra.Command{
-		Use:   "run [image]",
+		Use:   "run [image] [-- args]",
 		Short: "Submit and run a RHINO job",
 		Long:  "\nSubmit an MPI function/project and run it as a RHINO job",
 		Example: `  rhino run hello:v1.0 --namespace user_space
+  rhino run hello:v1.0 --name hello-test
+  rhino run --np 4 -- arg1 arg2   # in a project directory, using the image in rhino.yaml
   rhino run foo/matmul:v2.1 --np 4 -- arg1 arg2 
-  rhino run mpi/testbench -n 32 -t 800 --server 10.0.0.7 --dir /mnt -- --in=/data/file --out=/data/out`,
+  rhino run mpi/testbench -n 32 -t 800 --server 10.0.0.7 --dir /mnt -- --in=/data/file --out=/data/out
+  rhino run foo/matmul:v2.1 --np 4 --dry-run=client -o yaml > matmul.yaml
+  rhino run foo/matmul:v2.1 --np 4 --follow --timeout 30m -- arg1 arg2
+  rhino run foo/solver:v1 --exec preprocess -- --mesh /data/mesh`,
 		RunE: runOpts.run,
 	}
 
+	runCmd.Flags().StringVar(&runOpts.jobName, "name", "", "the name of the RHINO job. By default, a unique name is generated from the image name")
 	runCmd.Flags().StringVar(&runOpts.dataServer, "server", "", "IP address of an NFS server")
 	runCmd.Flags().StringVar(&runOpts.dataPath, "dir", "", "a directory in the NFS server, to store data and shared with all the MPI processes")
-	runCmd.MarkFlagsRequiredTogether("server", "dir")
 	runCmd.Flags().IntVar(&runOpts.parallel, "np", 1, "the number of MPI processes")
+	runCmd.Flags().StringVar(&runOpts.exec, "exec", "", "the executable to run, for an image holding several ones. By default, the default executable of the image")
 	runCmd.Flags().IntVarP(&runOpts.timeToLive, "ttl", "t", 600, "Time To Live (seconds). The RHINO job will be deleted after this time, whether it is completed or not.")
-	runCmd.Flags().StringVarP(&runOpts.namespace, "namespace", "n", "", "the namespace of the RHINO job")
-	runCmd.Flags().StringVar(&runOpts.kubeconfig, "kubeconfig", "", "the path of the kubeconfig file")
+	runOpts.addKubeFlags(runCmd.Flags())
+	runCmd.Flags().StringVar(&runOpts.dryRun, "dry-run", "none", `must be "none", "client" or "server". If client, only print the RHINO job that would be submitted. If server, submit it as a server-side dry run without creating it`)
+	runCmd.Flags().Lookup("dry-run").NoOptDefVal = "client"
+	runCmd.Flags().StringVarP(&runOpts.output, "output", "o", "", "print the RHINO job in the given format: yaml or json")
+	runCmd.Flags().BoolVar(&runOpts.wait, "wait", false, "wait until the RHINO job is completed, and exit with an error if it fails")
+	runCmd.Flags().BoolVar(&runOpts.follow, "follow", false, "like --wait, and also print every status change of the RHINO job")
+	runCmd.Flags().DurationVar(&runOpts.timeout, "timeout", 0, "the maximum time to wait with --wait or --follow, e.g. 30s or 10m. 0 means no timeout")
 
 	return runCmd
 }
 
 func (r *RunOptions) run(cmd *cobra.Command, args []string) error {
-	// Check the arguments
-	if len(args) == 0 {
+	// Check the arguments, taking the image from the project descriptor if there is one
+	project, err := loadProjectConfig(".")
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 && (project == nil || project.Image == "") {
 		cmd.Help()
 		return nil
 	}
-	r.funcName = getFuncName(args[0])
+	image, appArgs, err := splitImageArgs(cmd.ArgsLenAtDash(), args, project)
+	if err != nil {
+		return err
+	}
+	if r.jobName != "" {
+		if errs := validation.IsDNS1123Label(r.jobName); len(errs) > 0 {
+			return fmt.Errorf("invalid RHINO job name (--name) %q: %s", r.jobName, strings.Join(errs, ", "))
+		}
+		if len(r.jobName) > maxRhinoJobNameLength {
+			return fmt.Errorf("the RHINO job name (--name) cannot exceed %d characters", maxRhinoJobNameLength)
+		}
+	}
+	if r.dryRun != "none" && r.dryRun != "client" && r.dryRun != "server" {
+		return fmt.Errorf(`the dry run mode (--dry-run) must be "none", "client" or "server"`)
+	}
+	if r.output != "" && r.output != "yaml" && r.output != "json" {
+		return fmt.Errorf("the output format (-o) must be yaml or json")
+	}
+	if (r.wait || r.follow) && r.dryRun != "none" {
+		return fmt.Errorf("--wait and --follow cannot be used with --dry-run")
+	}
+	if r.timeout < 0 {
+		return fmt.Errorf("the timeout (--timeout) must be greater than or equal to 0")
+	}
+	if err := r.complete(cmd); err != nil {
+		return err
+	}
+
+	// Take the options not given on the command line from the project descriptor,
+	// and then from the profile
+	r.applyProfile(cmd.Flags(), r.profile)
+	if project != nil {
+		r.applyProjectConfig(cmd.Flags(), project)
+		if len(appArgs) == 0 {
+			appArgs = project.Run.Args
+		}
+	}
+	// The NFS server of the profile is the default server of a directory given without one
+	if r.dataServer == "" && r.dataPath != "" {
+		r.dataServer = r.profile.NFSServer
+	}
+	args = append([]string{image}, appArgs...)
+	if r.appExec, err = resolveExec(localImageLabels(image), project, r.exec, "./"); err != nil {
+		return err
+	}
+	r.funcName = toDNS1123Label(getFuncName(image), maxRhinoJobNameLength-generatedNameSuffixLength-1)
 	if r.parallel < 1 {
 		return fmt.Errorf("the number of MPI processes (--np) must be greater than 0")
 	}
 	if r.timeToLive < 0 {
 		return fmt.Errorf("the time to live (--ttl) must be greater than or equal to 0")
 	}
-	if r.kubeconfig == "" {
-		if home := homedir.HomeDir(); home != "" {
-			r.kubeconfig = filepath.Join(home, ".kube", "config")
-		} else {
-			return fmt.Errorf("kubeconfig file not found, please use --config to specify the absolute path")
+	if (r.dataServer == "") != (r.dataPath == "") {
+		return fmt.Errorf("the NFS server (--server) and directory (--dir) must be set together")
+	}
+
+	// Only print the RHINO job in the client dry run mode
+	if r.dryRun == "client" {
+		if r.output == "" {
+			r.output = "yaml"
 		}
+		return r.printRhinoJob(cmd.OutOrStdout(), r.newRhinoJob(args))
 	}
 
-	dynamicClient, currentNamespace, err := buildFromKubeconfig(r.kubeconfig)
+	// Create a RHINO job
+	dynamicClient, err := r.rhinoJobClient()
 	if err != nil {
 		return err
 	}
-	if r.namespace == "" {
-		r.namespace = *currentNamespace
+	if err := r.validateRhinoJob(dynamicClient, args, cmd.ErrOrStderr()); err != nil {
+		return err
 	}
-
-	// Create a RHINO job
-	_, err = r.runRhinoJob(dynamicClient, args)
+	createdRhinoJob, err := r.runRhinoJob(dynamicClient, args)
 	if err != nil {
 		fmt.Println(err.Error())
-		return fmt.Errorf("failed to create a RHINO job")
+		return fmt.Errorf("failed to create a RHINO job, run 'rhino doctor' to check the environment and the cluster")
+	}
+	if r.output != "" {
+		if err := r.printRhinoJob(cmd.OutOrStdout(), createdRhinoJob); err != nil {
+			return err
+		}
+	} else if r.dryRun == "server" {
+		fmt.Println("RhinoJob", createdRhinoJob.Name, "created (server dry run)")
+	} else {
+		fmt.Println("RhinoJob", createdRhinoJob.Name, "created")
+	}
+
+	if r.wait || r.follow {
+		return r.waitRhinoJob(dynamicClient, createdRhinoJob.Name)
 	}
-	fmt.Println("RhinoJob", r.funcName, "created")
 	return nil
 }
 
-func (r *RunOptions) printYAML(args []string) (yamlFile string) {
-	yamlFile = `apiVersion: openrhino.org/v1alpha1
-kind: RhinoJob
-metadata:
-  labels:
-    app.kubernetes.io/name: rhinojob 
-    app.kubernetes.io/instance: "`
-	yamlFile += r.funcName + `"
-    app.kubernetes.io/part-of: rhino-operator
-    app.kubernetes.io/managed-by: kustomize
-    app.kubernetes.io/created-by: rhino-operator
-  name: "`
-	yamlFile += r.funcName + `"
-spec:
-  image: "`
-	yamlFile += args[0] + `"
-  ttl: `
-	yamlFile += strconv.Itoa(r.timeToLive) + `
-  parallelism: `
-	yamlFile += strconv.Itoa(r.parallel) + ` 
-  appExec: "./mpi-func"`
-	if len(args) > 1 {
-		yamlFile += `
-  appArgs: [`
-		for i := 1; i < len(args); i++ {
-			yamlFile += `"` + args[i] + `", `
+// waitRhinoJob blocks until the RHINO job is completed, and returns an error if it fails
+func (r *RunOptions) waitRhinoJob(client dynamic.Interface, name string) error {
+	ctx, cancel := contextWithTimeout(r.timeout)
+	defer cancel()
+
+	var onChange func(rhinojob.JobStatus)
+	if r.follow {
+		onChange = func(status rhinojob.JobStatus) {
+			fmt.Printf("%s RhinoJob %s is %s\n", time.Now().Format("15:04:05"), name, status)
 		}
-		yamlFile += `]`
+	}
+	if err := waitForRhinoJob(ctx, client, r.rhinoJobResource(), r.namespace, name, rhinojob.Completed, onChange); err != nil {
+		return err
+	}
+	fmt.Println("RhinoJob", name, "completed")
+	return nil
+}
+
+func (r *RunOptions) printRhinoJob(w io.Writer, rj *rhinojob.RhinoJob) error {
+	obj, err := rhinoJobToUnstructured(rj)
+	if err != nil {
+		return err
+	}
+	return printObject(w, obj.Object, r.output)
+}
+
+// applyProfile takes the options not given on the command line from the profile
+func (r *RunOptions) applyProfile(flags *pflag.FlagSet, profile *Profile) {
+	if !flags.Changed("ttl") && profile.TTL != nil {
+		r.timeToLive = *profile.TTL
+	}
+}
+
+// applyProjectConfig takes the options not given on the command line from the project descriptor
+func (r *RunOptions) applyProjectConfig(flags *pflag.FlagSet, project *ProjectConfig) {
+	if !flags.Changed("np") && project.Run.NP != nil {
+		r.parallel = *project.Run.NP
+	}
+	if !flags.Changed("ttl") && project.Run.TTL != nil {
+		r.timeToLive = *project.Run.TTL
+	}
+	if !flags.Changed("server") && project.Run.Server != "" {
+		r.dataServer = project.Run.Server
+	}
+	if !flags.Changed("dir") && project.Run.Dir != "" {
+		r.dataPath = project.Run.Dir
+	}
+}
+
+// newRhinoJob builds the RhinoJob object to be submitted from the run options and arguments.
+// The job is built as a typed struct so that any argument string reaches the cluster unchanged.
+func (r *RunOptions) newRhinoJob(args []string) *rhinojob.RhinoJob {
+	parallelism := int32(r.parallel)
+	ttl := int32(r.timeToLive)
+	appExec := r.appExec
+	if appExec == "" {
+		appExec = "./" + defaultExecName
+	}
+	rj := &rhinojob.RhinoJob{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rhinojob.GroupVersion.String(),
+			Kind:       "RhinoJob",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: r.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "rhinojob",
+				"app.kubernetes.io/instance":   r.funcName,
+				"app.kubernetes.io/part-of":    "rhino-operator",
+				"app.kubernetes.io/managed-by": "kustomize",
+				"app.kubernetes.io/created-by": "rhino-operator",
+			},
+		},
+		Spec: rhinojob.RhinoJobSpec{
+			Image:       args[0],
+			TTL:         &ttl,
+			Parallelism: &parallelism,
+			AppExec:     appExec,
+		},
+	}
+	// Without an explicit name, let the API server generate a unique one,
+	// so that the same image can be run again before the previous job expires
+	if r.jobName != "" {
+		rj.Name = r.jobName
+	} else {
+		rj.GenerateName = r.funcName + "-"
+	}
+	if len(args) > 1 {
+		rj.Spec.AppArgs = append([]string{}, args[1:]...)
 	}
 	if len(r.dataServer) != 0 {
-		yamlFile += `
-  dataServer: "` + r.dataServer + `"`
-		yamlFile += `
-  dataPath: "` + r.dataPath + `"`
+		rj.Spec.DataServer = r.dataServer
+		rj.Spec.DataPath = r.dataPath
 	}
-	return yamlFile
+	return rj
 }
 
-func (r *RunOptions) runRhinoJob(client dynamic.Interface, args []string) (*rhinojob.RhinoJobList, error) {
-	decoder := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
-	obj := &unstructured.Unstructured{}
-	rhinojobYAML := r.printYAML(args)
-	if _, _, err := decoder.Decode([]byte(rhinojobYAML), nil, obj); err != nil {
-		return nil, err
+// createOptions returns the options used to submit the RHINO job,
+// asking the API server not to persist it in the server dry run mode
+func (r *RunOptions) createOptions() metav1.CreateOptions {
+	if r.dryRun == "server" {
+		return metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}
 	}
-	createdRhinoJob, err := client.Resource(RhinoJobGVR).Namespace(r.namespace).Create(context.TODO(), obj, metav1.CreateOptions{})
+	return metav1.CreateOptions{}
+}
 
+// validateRhinoJob checks the RHINO job to be submitted against the schema of the RhinoJob CRD,
+// so that invalid fields are reported with their paths before anything is submitted
+func (r *RunOptions) validateRhinoJob(client dynamic.Interface, args []string, warnings io.Writer) error {
+	gvr := r.rhinoJobResource()
+	obj, err := rhinoJobToUnstructured(r.newRhinoJob(args))
 	if err != nil {
+		return err
+	}
+	if obj, err = convertRhinoJob(obj, gvr); err != nil {
+		return err
+	}
+	return validateRhinoJob(obj, r.rhinoJobSchema(client, gvr, warnings))
+}
+
+func (r *RunOptions) runRhinoJob(client dynamic.Interface, args []string) (*rhinojob.RhinoJob, error) {
+	obj, err := rhinoJobToUnstructured(r.newRhinoJob(args))
+	if err != nil {
+		return nil, err
+	}
+	gvr := r.rhinoJobResource()
+	if obj, err = convertRhinoJob(obj, gvr); err != nil {
 		return nil, err
 	}
-	data, err := createdRhinoJob.MarshalJSON()
+	createdRhinoJob, err := client.Resource(gvr).Namespace(r.namespace).Create(context.TODO(), obj, r.createOptions())
 	if err != nil {
 		return nil, err
 	}
-	var rj rhinojob.RhinoJobList
-	if err := json.Unmarshal(data, &rj); err != nil {
+	rj, err := rhinoJobFromUnstructured(createdRhinoJob)
+	if err != nil {
 		return nil, err
 	}
 	return &rj, nil
@@ -16,13 +16,21 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
-	"time"
 
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/yaml"
 )
 
 const testFuncRunNamespace = "rhino-test"
@@ -44,13 +52,11 @@ func TestRunSingleJob(t *testing.T) {
 
 	// test run command
 	execShellCmd("kubectl", []string{"create", "namespace", testFuncRunNamespace})
-	rootCmd.SetArgs([]string{"run", testFuncImageName, "--namespace", testFuncRunNamespace})
+	rootCmd.SetArgs([]string{"run", testFuncImageName, "--namespace", testFuncRunNamespace, "--wait", "--timeout", "5m"})
 	err = rootCmd.Execute()
 	assert.Equal(t, nil, err, "test run failed: %s", errorMessage(err))
 
-	// use `kubectl get rhinojob` to check whether rhinojob has been created
-	fmt.Println("Wait 60s and check job status")
-	time.Sleep(60 * time.Second) //60s is long enough for this simple test job to complete
+	// use `kubectl get rhinojob` to check whether rhinojob has been completed
 	cmdOutput, err := execShellCmd("kubectl", []string{"get", "rhinojob", "--namespace", testFuncRunNamespace})
 	assert.Equal(t, nil, err, "test run failed: %s", errorMessage(err))
 	assert.Equal(t, true, strings.Contains(cmdOutput, "Completed"), "rhinojob failed to start")
@@ -62,3 +68,174 @@ func TestRunSingleJob(t *testing.T) {
 	execShellCmd("docker", []string{"rmi", testFuncImageName})
 	execShellCmd("sh", []string{"-c", "docker rmi -f $(docker images | grep none | grep second | awk '{print $3}')"})
 }
+
+// newFakeDynamicClient returns a fake dynamic client that serves RhinoJobs
+func newFakeDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{RhinoJobGVR: "RhinoJobList"}, objects...)
+}
+
+// check if the app args survive the trip into the cluster unchanged
+func TestRunRhinoJobArgsRoundTrip(t *testing.T) {
+	client := newFakeDynamicClient()
+	runOpts := &RunOptions{
+		parallel:    4,
+		timeToLive:  300,
+		dataServer:  "10.0.0.7",
+		dataPath:    "/mnt",
+		funcName:    "test-run-round-trip",
+		jobName:     "test-run-round-trip",
+		KubeOptions: KubeOptions{namespace: testFuncRunNamespace},
+	}
+	args := []string{"foo/test-run-round-trip:v1",
+		`--msg="hello, world"`, `C:\data\in`, "key: value", "line1\nline2", "", "[1, 2]"}
+
+	created, err := runOpts.runRhinoJob(client, args)
+	assert.Equal(t, nil, err, "test run failed: %s", errorMessage(err))
+	assert.Equal(t, args[1:], created.Spec.AppArgs)
+
+	obj, err := client.Resource(RhinoJobGVR).Namespace(testFuncRunNamespace).Get(context.TODO(), runOpts.jobName, metav1.GetOptions{})
+	assert.Equal(t, nil, err, "test run failed: %s", errorMessage(err))
+	var rj rhinojob.RhinoJob
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &rj)
+	assert.Equal(t, nil, err, "test run failed: %s", errorMessage(err))
+
+	assert.Equal(t, args[0], rj.Spec.Image)
+	assert.Equal(t, args[1:], rj.Spec.AppArgs)
+	assert.Equal(t, int32(4), *rj.Spec.Parallelism)
+	assert.Equal(t, int32(300), *rj.Spec.TTL)
+	assert.Equal(t, "./mpi-func", rj.Spec.AppExec)
+	assert.Equal(t, "10.0.0.7", rj.Spec.DataServer)
+	assert.Equal(t, "/mnt", rj.Spec.DataPath)
+}
+
+// check if appArgs is omitted when no app args are given
+func TestRunRhinoJobWithoutArgs(t *testing.T) {
+	runOpts := &RunOptions{parallel: 1, funcName: "test-run-no-args", KubeOptions: KubeOptions{namespace: testFuncRunNamespace}}
+	rj := runOpts.newRhinoJob([]string{"test-run-no-args:v1"})
+	assert.Equal(t, 0, len(rj.Spec.AppArgs))
+	assert.Equal(t, "", rj.Spec.DataServer)
+	assert.Equal(t, "", rj.Spec.DataPath)
+}
+
+// writeTestKubeconfig writes a kubeconfig file whose current context uses the given namespace.
+// The server in it is never contacted by the tests using it.
+func writeTestKubeconfig(t *testing.T, namespace string) string {
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: test-cluster
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: test-context
+  context:
+    cluster: test-cluster
+    user: test-user
+    namespace: ` + namespace + `
+current-context: test-context
+users:
+- name: test-user
+  user:
+    token: test-token
+`
+	path := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(path, []byte(kubeconfig), 0600)
+	assert.Equal(t, nil, err, "failed to write the test kubeconfig: %s", errorMessage(err))
+	return path
+}
+
+// check if the client dry run prints the RHINO job with the same defaulting as a real run
+func TestRunDryRunClient(t *testing.T) {
+	kubeconfig := writeTestKubeconfig(t, testFuncRunNamespace)
+	rootCmd := NewRootCommand()
+	out := new(bytes.Buffer)
+	rootCmd.SetOut(out)
+	rootCmd.SetArgs([]string{"run", "foo/test-run-dry-run:v1", "--np", "4", "--ttl", "300",
+		"--server", "10.0.0.7", "--dir", "/mnt", "--kubeconfig", kubeconfig, "--dry-run=client", "-o", "yaml", "--", "a:b", `"c"`})
+	err := rootCmd.Execute()
+	assert.Equal(t, nil, err, "test run dry run failed: %s", errorMessage(err))
+
+	var rj rhinojob.RhinoJob
+	err = yaml.UnmarshalStrict(out.Bytes(), &rj)
+	assert.Equal(t, nil, err, "test run dry run failed: %s", errorMessage(err))
+	assert.Equal(t, "RhinoJob", rj.Kind)
+	assert.Equal(t, "openrhino.org/v1alpha1", rj.APIVersion)
+	assert.Equal(t, "", rj.Name)
+	assert.Equal(t, "test-run-dry-run-", rj.GenerateName)
+	assert.Equal(t, testFuncRunNamespace, rj.Namespace)
+	assert.Equal(t, "foo/test-run-dry-run:v1", rj.Spec.Image)
+	assert.Equal(t, int32(4), *rj.Spec.Parallelism)
+	assert.Equal(t, int32(300), *rj.Spec.TTL)
+	assert.Equal(t, []string{"a:b", `"c"`}, rj.Spec.AppArgs)
+	assert.Equal(t, "10.0.0.7", rj.Spec.DataServer)
+	assert.Equal(t, "/mnt", rj.Spec.DataPath)
+	assert.Equal(t, false, strings.Contains(out.String(), "status"), "the dry run output should not contain a status")
+}
+
+// check if the server dry run asks the API server not to persist the RHINO job
+func TestRunDryRunServerOptions(t *testing.T) {
+	runOpts := &RunOptions{dryRun: "server"}
+	assert.Equal(t, []string{metav1.DryRunAll}, runOpts.createOptions().DryRun)
+	runOpts.dryRun = "none"
+	assert.Equal(t, 0, len(runOpts.createOptions().DryRun))
+}
+
+// check if invalid dry run modes and output formats are reported
+func TestRunDryRunInvalidFlags(t *testing.T) {
+	kubeconfig := writeTestKubeconfig(t, testFuncRunNamespace)
+	rootCmd := NewRootCommand()
+	rootCmd.SetArgs([]string{"run", "foo/test-run-dry-run:v1", "--kubeconfig", kubeconfig, "--dry-run=all"})
+	err := rootCmd.Execute()
+	assert.Equal(t, fmt.Errorf(`the dry run mode (--dry-run) must be "none", "client" or "server"`), err)
+
+	rootCmd = NewRootCommand()
+	rootCmd.SetArgs([]string{"run", "foo/test-run-dry-run:v1", "--kubeconfig", kubeconfig, "--dry-run", "-o", "table"})
+	err = rootCmd.Execute()
+	assert.Equal(t, fmt.Errorf("the output format (-o) must be yaml or json"), err)
+}
+
+// check if the job name is taken from --name, or generated from the image name
+func TestRunRhinoJobName(t *testing.T) {
+	kubeconfig := writeTestKubeconfig(t, testFuncRunNamespace)
+	for _, c := range []struct {
+		args         []string
+		name         string
+		generateName string
+	}{
+		{[]string{"foo/test_Run_Name:v1"}, "", "test-run-name-"},
+		{[]string{"foo/test-run-name:v1", "--name", "my-job"}, "my-job", ""},
+	} {
+		rootCmd := NewRootCommand()
+		out := new(bytes.Buffer)
+		rootCmd.SetOut(out)
+		rootCmd.SetArgs(append([]string{"run", "--kubeconfig", kubeconfig, "--dry-run"}, c.args...))
+		err := rootCmd.Execute()
+		assert.Equal(t, nil, err, "test run name failed: %s", errorMessage(err))
+
+		var rj rhinojob.RhinoJob
+		err = yaml.Unmarshal(out.Bytes(), &rj)
+		assert.Equal(t, nil, err, "test run name failed: %s", errorMessage(err))
+		assert.Equal(t, c.name, rj.Name)
+		assert.Equal(t, c.generateName, rj.GenerateName)
+	}
+
+	rootCmd := NewRootCommand()
+	rootCmd.SetArgs([]string{"run", "foo/test-run-name:v1", "--kubeconfig", kubeconfig, "--name", "My_Job"})
+	err := rootCmd.Execute()
+	assert.NotEqual(t, nil, err, "test run name failed: invalid name not reported")
+}
+
+func TestToDNS1123Label(t *testing.T) {
+	for input, expected := range map[string]string{
+		"hello":          "hello",
+		"Hi_World":       "hi-world",
+		"my.func__v2":    "my-func-v2",
+		"-_leading-":     "leading",
+		"___":            "rhinojob",
+		"abcdefghijklmn": "abcdefghij",
+		"abcdefghi_jkl":  "abcdefghi",
+	} {
+		assert.Equal(t, expected, toDNS1123Label(input, 10), "toDNS1123Label(%q)", input)
+	}
+}
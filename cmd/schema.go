@@ -0,0 +1,195 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	apiservervalidation "k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/dynamic"
+)
+
+// The name of the RhinoJob CRD
+var rhinoJobCRDName = RhinoJobGVR.GroupResource().String()
+
+// rhinoJobSchema returns the OpenAPI schema of the RhinoJob API at the version of gvr.
+// The schema is read from the CRD in the cluster and cached in ~/.rhino/cache. When the CRD
+// cannot be read, the cached schema is used, or else the one of the embedded operator manifests,
+// and a warning is printed. It returns nil, with a warning, when no schema is found at all.
+func (k *KubeOptions) rhinoJobSchema(client dynamic.Interface, gvr schema.GroupVersionResource,
+	warnings io.Writer) *apiextensionsv1.JSONSchemaProps {
+	cachePath := k.schemaCachePath(gvr)
+	obj, err := client.Resource(crdGVR).Get(context.TODO(), rhinoJobCRDName, metav1.GetOptions{})
+	if err == nil {
+		var props *apiextensionsv1.JSONSchemaProps
+		if props, err = crdSchema(obj, gvr.Version); err == nil {
+			if cachePath != "" {
+				saveSchema(cachePath, props)
+			}
+			return props
+		}
+	}
+
+	if cachePath != "" {
+		if props, cacheErr := loadSchema(cachePath); cacheErr == nil {
+			fmt.Fprintf(warnings, "Warning: cannot read the RhinoJob CRD (%v), validating with the schema cached in %s\n", err, cachePath)
+			return props
+		}
+	}
+	if props, embeddedErr := embeddedSchema(gvr.Version); embeddedErr == nil {
+		fmt.Fprintf(warnings, "Warning: cannot read the RhinoJob CRD (%v), validating with the schema embedded in rhino\n", err)
+		return props
+	}
+	fmt.Fprintf(warnings, "Warning: cannot read the RhinoJob CRD (%v), the RHINO job is not validated before submission\n", err)
+	return nil
+}
+
+// crdSchema returns the OpenAPI schema of a version of a CRD
+func crdSchema(obj *unstructured.Unstructured, version string) (*apiextensionsv1.JSONSchemaProps, error) {
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &crd); err != nil {
+		return nil, fmt.Errorf("failed to read CustomResourceDefinition %s: %v", obj.GetName(), err)
+	}
+	for _, crdVersion := range crd.Spec.Versions {
+		if crdVersion.Name == version && crdVersion.Schema != nil && crdVersion.Schema.OpenAPIV3Schema != nil {
+			return crdVersion.Schema.OpenAPIV3Schema, nil
+		}
+	}
+	return nil, fmt.Errorf("CustomResourceDefinition %s has no schema for version %s", crd.Name, version)
+}
+
+// embeddedSchema returns the schema of the RhinoJob CRD in the latest embedded operator manifests
+func embeddedSchema(version string) (*apiextensionsv1.JSONSchemaProps, error) {
+	_, objs, err := loadOperatorManifests("", operatorNamespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if obj.GetKind() == "CustomResourceDefinition" && obj.GetName() == rhinoJobCRDName {
+			return crdSchema(obj, version)
+		}
+	}
+	return nil, fmt.Errorf("no CustomResourceDefinition %s is embedded in rhino", rhinoJobCRDName)
+}
+
+// schemaCachePath returns the path of the cached schema of the RhinoJob API of the cluster,
+// next to the rhino config. It returns an empty path when the cluster or the home directory is unknown.
+func (k *KubeOptions) schemaCachePath(gvr schema.GroupVersionResource) string {
+	config, err := k.restConfig()
+	if err != nil {
+		return ""
+	}
+	configPath, err := userConfigPath()
+	if err != nil {
+		return ""
+	}
+	// One directory per API server, e.g. https://10.0.0.1:6443 is cached in 10.0.0.1_6443
+	server := strings.TrimPrefix(strings.TrimPrefix(config.Host, "https://"), "http://")
+	server = strings.NewReplacer("/", "_", ":", "_").Replace(strings.TrimSuffix(server, "/"))
+	return filepath.Join(filepath.Dir(configPath), "cache", server, gvr.GroupResource().String()+"_"+gvr.Version+".json")
+}
+
+func loadSchema(path string) (*apiextensionsv1.JSONSchemaProps, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	props := &apiextensionsv1.JSONSchemaProps{}
+	if err := json.Unmarshal(data, props); err != nil {
+		return nil, fmt.Errorf("invalid cached schema %s: %v", path, err)
+	}
+	return props, nil
+}
+
+// saveSchema caches a schema. The cache is only an optimization, so failures are ignored.
+func saveSchema(path string, props *apiextensionsv1.JSONSchemaProps) {
+	data, err := json.Marshal(props)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	os.WriteFile(path, data, 0644)
+}
+
+// validateRhinoJob checks a RhinoJob against the OpenAPI schema of its CRD, and against the limits
+// of the RHINO operator that the schema does not hold: the length of the name and the NFS server.
+// Unknown fields, which the API server would silently drop, are reported too.
+// The error lists every invalid field with its path, e.g. spec.ttl.
+func validateRhinoJob(obj *unstructured.Unstructured, props *apiextensionsv1.JSONSchemaProps) error {
+	var allErrs field.ErrorList
+	if props != nil {
+		internal := &apiextensions.JSONSchemaProps{}
+		if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(props, internal, nil); err != nil {
+			return err
+		}
+		validator, _, err := apiservervalidation.NewSchemaValidator(&apiextensions.CustomResourceValidation{OpenAPIV3Schema: internal})
+		if err != nil {
+			return fmt.Errorf("invalid RhinoJob schema: %v", err)
+		}
+		allErrs = append(allErrs, apiservervalidation.ValidateCustomResource(nil, obj.UnstructuredContent(), validator)...)
+
+		if structural, err := structuralschema.NewStructural(internal); err == nil {
+			pruned := runtime.DeepCopyJSON(obj.UnstructuredContent())
+			unknown := pruning.PruneWithOptions(pruned, structural, true, structuralschema.UnknownFieldPathOptions{TrackUnknownFieldPaths: true})
+			for _, path := range unknown {
+				allErrs = append(allErrs, field.Forbidden(field.NewPath(path), "unknown field"))
+			}
+		}
+	}
+
+	if name := obj.GetName(); name != "" {
+		namePath := field.NewPath("metadata", "name")
+		for _, msg := range validation.IsDNS1123Label(name) {
+			allErrs = append(allErrs, field.Invalid(namePath, name, msg))
+		}
+		if len(name) > maxRhinoJobNameLength {
+			allErrs = append(allErrs, field.TooLongMaxLength(namePath, name, maxRhinoJobNameLength))
+		}
+	}
+	if server, _, _ := unstructured.NestedString(obj.Object, "spec", "dataServer"); server != "" && net.ParseIP(server) == nil {
+		if len(validation.IsDNS1123Subdomain(server)) > 0 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "dataServer"), server, "must be an IP address or a host name"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	lines := make([]string, len(allErrs))
+	for i, err := range allErrs {
+		lines[i] = "  " + err.Error()
+	}
+	return fmt.Errorf("invalid RhinoJob:\n%s", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestValidateRhinoJob(t *testing.T) {
+	props, err := embeddedSchema("v1alpha1")
+	assert.Equal(t, nil, err, "test validate failed: %s", errorMessage(err))
+
+	runOpts := &RunOptions{parallel: 4, timeToLive: 600, funcName: "hello", dataServer: "nfs.example.com", dataPath: "/mnt"}
+	obj, err := rhinoJobToUnstructured(runOpts.newRhinoJob([]string{"hello:v1", "--in", "a b"}))
+	assert.Equal(t, nil, err, "test validate failed: %s", errorMessage(err))
+	assert.Equal(t, nil, validateRhinoJob(obj, props))
+
+	unstructured.SetNestedField(obj.Object, int64(-1), "spec", "ttl")
+	unstructured.SetNestedField(obj.Object, int64(0), "spec", "parallelism")
+	unstructured.SetNestedField(obj.Object, "10.0.0.", "spec", "dataServer")
+	unstructured.SetNestedField(obj.Object, int64(60), "spec", "tll")
+	unstructured.RemoveNestedField(obj.Object, "spec", "image")
+	obj.SetName(strings.Repeat("a", maxRhinoJobNameLength+1))
+	err = validateRhinoJob(obj, props)
+	lines := strings.Split(errorMessage(err), "\n")
+	assert.Equal(t, "invalid RhinoJob:", lines[0])
+	for _, expected := range []string{
+		"spec.image: Required value",
+		"spec.parallelism: Invalid value: 0: spec.parallelism in body should be greater than or equal to 1",
+		"spec.ttl: Invalid value: -1: spec.ttl in body should be greater than or equal to 0",
+		"spec.tll: Forbidden: unknown field",
+		"metadata.name: Too long: may not be longer than 54",
+		`spec.dataServer: Invalid value: "10.0.0.": must be an IP address or a host name`,
+	} {
+		found := false
+		for _, line := range lines[1:] {
+			found = found || strings.HasPrefix(line, "  "+expected)
+		}
+		assert.True(t, found, "%q not found in:\n%s", expected, errorMessage(err))
+	}
+	assert.Equal(t, 7, len(lines), "unexpected error:\n%s", errorMessage(err))
+}
+
+// check if the schema is read from the cluster and cached, and if the cached and then
+// the embedded schemas are used when the CRD cannot be read
+func TestRhinoJobSchemaFallback(t *testing.T) {
+	configPath := useTestUserConfig(t)
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	assert.Equal(t, nil, os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: cluster-a
+  cluster:
+    server: https://10.0.0.1:6443
+contexts:
+- name: context-a
+  context:
+    cluster: cluster-a
+current-context: context-a
+`), 0600))
+	kubeOpts, err := newTestKubeOptions(t, "--kubeconfig", kubeconfig)
+	assert.Equal(t, nil, err, "test schema failed: %s", errorMessage(err))
+
+	_, objs, err := loadOperatorManifests("", operatorNamespace)
+	assert.Equal(t, nil, err, "test schema failed: %s", errorMessage(err))
+	var crd *unstructured.Unstructured
+	for _, obj := range objs {
+		if obj.GetName() == rhinoJobCRDName {
+			crd = obj
+		}
+	}
+	// a schema only found in the cluster, to tell it apart from the embedded one
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	unstructured.SetNestedField(versions[0].(map[string]interface{}), "from the cluster", "schema", "openAPIV3Schema", "description")
+	unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions")
+
+	warnings := new(bytes.Buffer)
+	props := kubeOpts.rhinoJobSchema(newFakeDynamicClient(crd), RhinoJobGVR, warnings)
+	assert.Equal(t, "from the cluster", props.Description)
+	assert.Equal(t, "", warnings.String())
+	cachePath := filepath.Join(filepath.Dir(configPath), "cache", "10.0.0.1_6443", "rhinojobs.openrhino.org_v1alpha1.json")
+	assert.FileExists(t, cachePath)
+
+	props = kubeOpts.rhinoJobSchema(newFakeDynamicClient(), RhinoJobGVR, warnings)
+	assert.Equal(t, "from the cluster", props.Description)
+	assert.Contains(t, warnings.String(), "validating with the schema cached in "+cachePath)
+
+	os.Remove(cachePath)
+	warnings.Reset()
+	props = kubeOpts.rhinoJobSchema(newFakeDynamicClient(), RhinoJobGVR, warnings)
+	assert.Equal(t, "RhinoJob is the Schema for the rhinojobs API", props.Description)
+	assert.Contains(t, warnings.String(), "validating with the schema embedded in rhino")
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+type UninstallOptions struct {
+	version           string
+	yes               bool
+	operatorNamespace string
+	KubeOptions
+}
+
+func NewUninstallCommand() *cobra.Command {
+	uninstallOpts := &UninstallOptions{}
+	uninstallCmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Uninstall the RHINO operator",
+		Long:  "\nUninstall the RHINO operator and its CRDs installed by rhino install. Deleting the CRDs deletes all the RHINO jobs, so rhino asks for confirmation when some still exist.",
+		Example: `  rhino uninstall
+  rhino uninstall --operator-namespace rhino-system --yes`,
+		Args: cobra.NoArgs,
+		RunE: uninstallOpts.runUninstall,
+	}
+
+	uninstallCmd.Flags().StringVar(&uninstallOpts.version, "version", "", "the version of the RHINO operator to uninstall. By default, the latest version embedded in rhino")
+	uninstallCmd.Flags().BoolVarP(&uninstallOpts.yes, "yes", "y", false, "do not ask for confirmation when RHINO jobs still exist")
+	addOperatorNamespaceFlag(uninstallCmd.Flags(), &uninstallOpts.operatorNamespace)
+	uninstallOpts.addKubeFlags(uninstallCmd.Flags())
+
+	return uninstallCmd
+}
+
+func (u *UninstallOptions) runUninstall(cmd *cobra.Command, args []string) error {
+	if err := u.complete(cmd); err != nil {
+		return err
+	}
+	_, objs, err := loadOperatorManifests(u.version, u.operatorNamespace)
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := u.dynamicClient()
+	if err != nil {
+		return err
+	}
+	mapper, err := u.restMapper()
+	if err != nil {
+		return err
+	}
+	discoveryClient, err := u.discoveryClient()
+	if err != nil {
+		return err
+	}
+	// The RHINO jobs are only counted, so any served version will do, even one rhino cannot read
+	api, err := discoverRhinoJobAPI(discoveryClient)
+	if err != nil {
+		return err
+	}
+	if len(api.served) > 0 {
+		u.rhinoJobGVR = RhinoJobGVR
+		u.rhinoJobGVR.Version = api.served[0]
+	}
+	return u.uninstall(context.TODO(), dynamicClient, mapper, objs, cmd.InOrStdin(), cmd.OutOrStdout())
+}
+
+// uninstall deletes the objects of the RHINO operator in the reverse order of their installation,
+// after a confirmation if RHINO jobs still exist
+func (u *UninstallOptions) uninstall(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper,
+	objs []*unstructured.Unstructured, in io.Reader, out io.Writer) error {
+	rjList, err := client.Resource(u.rhinoJobResource()).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && len(rjList.Items) > 0 {
+		fmt.Fprintf(out, "Warning: %d RhinoJobs still exist, and will be deleted with their CRD\n", len(rjList.Items))
+		if !u.yes {
+			confirmed, err := askConfirmation(in, out, "Uninstall the RHINO operator?")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Fprintln(out, "Uninstall cancelled")
+				return nil
+			}
+		}
+	}
+
+	for i := len(objs) - 1; i >= 0; i-- {
+		obj := objs[i]
+		ri, err := resourceFor(client, mapper, obj)
+		if err != nil {
+			return err
+		}
+		kindName := strings.ToLower(obj.GetKind()) + "/" + obj.GetName()
+		err = ri.Delete(ctx, obj.GetName(), metav1.DeleteOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			fmt.Fprintf(out, "%s not found\n", kindName)
+		case err != nil:
+			return fmt.Errorf("failed to delete %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		default:
+			fmt.Fprintf(out, "%s deleted\n", kindName)
+		}
+	}
+	fmt.Fprintln(out, "The RHINO operator is uninstalled")
+	return nil
+}
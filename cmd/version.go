@@ -0,0 +1,176 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The build information of rhino, set with -ldflags -X by make build
+var (
+	cliVersion = "dev"
+	gitCommit  = ""
+	buildDate  = ""
+)
+
+type VersionOptions struct {
+	clientOnly        bool
+	operatorNamespace string
+	KubeOptions
+}
+
+func NewVersionCommand() *cobra.Command {
+	versionOpts := &VersionOptions{}
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the versions of rhino, the RHINO operator and the RhinoJob CRD",
+		Long:  "\nPrint the build information of rhino, and the versions of Kubernetes, the RhinoJob API and the RHINO operator found in the cluster",
+		Example: `  rhino version
+  rhino version --client`,
+		Args: cobra.NoArgs,
+		RunE: versionOpts.runVersion,
+	}
+
+	versionCmd.Flags().BoolVar(&versionOpts.clientOnly, "client", false, "only print the version of rhino, without connecting to the cluster")
+	addOperatorNamespaceFlag(versionCmd.Flags(), &versionOpts.operatorNamespace)
+	versionOpts.addKubeFlags(versionCmd.Flags())
+
+	return versionCmd
+}
+
+func (v *VersionOptions) runVersion(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	printClientVersion(out)
+	if v.clientOnly {
+		return nil
+	}
+	if err := v.complete(cmd); err != nil {
+		return err
+	}
+	clientset, err := v.clientset()
+	if err != nil {
+		return err
+	}
+	return printServerVersion(context.TODO(), clientset, v.operatorNamespace, out)
+}
+
+// printClientVersion prints the build information of rhino, read from the Go build info
+// when it was not set at link time
+func printClientVersion(out io.Writer) {
+	commit, date := gitCommit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && date == "":
+				date = setting.Value
+			}
+		}
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(w, "Client:")
+	fmt.Fprintf(w, "  Version:\t%s\n", cliVersion)
+	fmt.Fprintf(w, "  Git commit:\t%s\n", formatOptional(commit))
+	fmt.Fprintf(w, "  Build date:\t%s\n", formatOptional(date))
+	fmt.Fprintf(w, "  Go version:\t%s\n", runtime.Version())
+	fmt.Fprintf(w, "  Platform:\t%s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "  RhinoJob API:\t%s/%s\n", RhinoJobGVR.Group, strings.Join(supportedRhinoJobVersions, ", "))
+	w.Flush()
+}
+
+// printServerVersion prints the versions of Kubernetes, the RhinoJob API and the RHINO operator.
+// It returns an error when the RhinoJob API served by the cluster cannot be used by rhino.
+func printServerVersion(ctx context.Context, clientset kubernetes.Interface, namespace string, out io.Writer) error {
+	serverVersion, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return err
+	}
+	api, err := discoverRhinoJobAPI(clientset.Discovery())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(w, "Server:")
+	fmt.Fprintf(w, "  Kubernetes:\t%s\n", serverVersion.GitVersion)
+	gvr, apiErr := api.resource()
+	switch {
+	case len(api.served) == 0:
+		fmt.Fprintf(w, "  RhinoJob API:\t<not installed>\n")
+	case apiErr != nil:
+		fmt.Fprintf(w, "  RhinoJob API:\t%s/%s (preferred %s), not supported\n",
+			RhinoJobGVR.Group, strings.Join(api.served, ", "), api.preferred)
+	default:
+		fmt.Fprintf(w, "  RhinoJob API:\t%s/%s (preferred %s), using %s\n",
+			RhinoJobGVR.Group, strings.Join(api.served, ", "), api.preferred, gvr.Version)
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, operatorDeploymentName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		fmt.Fprintf(w, "  RHINO operator:\t<not installed>\n")
+	case apierrors.IsForbidden(err):
+		fmt.Fprintf(w, "  RHINO operator:\t<unknown, not allowed to read the deployment %s/%s>\n", namespace, operatorDeploymentName)
+	case err != nil:
+		w.Flush()
+		return err
+	default:
+		image := operatorImage(deployment)
+		fmt.Fprintf(w, "  RHINO operator:\t%s (%s)\n", operatorVersion(deployment, image), formatOptional(image))
+	}
+	w.Flush()
+	return apiErr
+}
+
+// operatorImage returns the image of the manager container of the RHINO operator
+func operatorImage(deployment *appsv1.Deployment) string {
+	containers := deployment.Spec.Template.Spec.Containers
+	for _, container := range containers {
+		if container.Name == "manager" {
+			return container.Image
+		}
+	}
+	if len(containers) > 0 {
+		return containers[len(containers)-1].Image
+	}
+	return ""
+}
+
+// operatorVersion returns the version of the RHINO operator, given by its recommended
+// version label or else by the tag of its image
+func operatorVersion(deployment *appsv1.Deployment, image string) string {
+	if version := deployment.Labels["app.kubernetes.io/version"]; version != "" {
+		return version
+	}
+	image = strings.SplitN(image, "@", 2)[0]
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "<unknown>"
+}
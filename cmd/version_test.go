@@ -0,0 +1,85 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestOperatorDeployment(image string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: operatorDeploymentName, Namespace: operatorNamespace},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "kube-rbac-proxy", Image: "gcr.io/kubebuilder/kube-rbac-proxy:v0.13.0"},
+			{Name: "manager", Image: image},
+		}}}},
+	}
+}
+
+func TestVersionClient(t *testing.T) {
+	rootCmd := NewRootCommand()
+	out := new(bytes.Buffer)
+	rootCmd.SetOut(out)
+	rootCmd.SetArgs([]string{"version", "--client"})
+	err := rootCmd.Execute()
+	assert.Equal(t, nil, err, "test version failed: %s", errorMessage(err))
+	assert.Regexp(t, `(?m)^Client:\n  Version: +dev\n`, out.String())
+	assert.Regexp(t, `(?m)^  RhinoJob API: +openrhino.org/v1alpha1$`, out.String())
+	assert.NotContains(t, out.String(), "Server:")
+}
+
+func TestVersionServer(t *testing.T) {
+	clientset := newTestDoctorClientset(nil, newTestOperatorDeployment("docker.io/openrhino/controller:v0.1.0"))
+	clientset.Resources = newTestDiscovery("v1alpha1").Resources
+	out := new(bytes.Buffer)
+	err := printServerVersion(context.TODO(), clientset, operatorNamespace, out)
+	assert.Equal(t, nil, err, "test version failed: %s", errorMessage(err))
+	assert.Equal(t, `Server:
+  Kubernetes:     v1.24.10
+  RhinoJob API:   openrhino.org/v1alpha1 (preferred v1alpha1), using v1alpha1
+  RHINO operator: v0.1.0 (docker.io/openrhino/controller:v0.1.0)
+`, out.String())
+
+	// the operator serves a version this rhino cannot use
+	clientset = newTestDoctorClientset(nil, newTestOperatorDeployment("openrhino/controller@sha256:0123"))
+	clientset.Resources = newTestDiscovery("v1beta1").Resources
+	out = new(bytes.Buffer)
+	err = printServerVersion(context.TODO(), clientset, operatorNamespace, out)
+	assert.Contains(t, errorMessage(err), "the RHINO operator is too new for this rhino")
+	assert.Equal(t, `Server:
+  Kubernetes:     v1.24.10
+  RhinoJob API:   openrhino.org/v1beta1 (preferred v1beta1), not supported
+  RHINO operator: <unknown> (openrhino/controller@sha256:0123)
+`, out.String())
+
+	// nothing is installed
+	clientset = newTestDoctorClientset(nil)
+	out = new(bytes.Buffer)
+	err = printServerVersion(context.TODO(), clientset, operatorNamespace, out)
+	assert.Contains(t, errorMessage(err), "the cluster does not serve rhinojobs.openrhino.org")
+	assert.Equal(t, `Server:
+  Kubernetes:     v1.24.10
+  RhinoJob API:   <not installed>
+  RHINO operator: <not installed>
+`, out.String())
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+type WaitOptions struct {
+	rhinojobNames []string
+	forStatus     string
+	timeout       time.Duration
+	KubeOptions
+}
+
+func NewWaitCommand() *cobra.Command {
+	waitOpts := &WaitOptions{}
+	waitCmd := &cobra.Command{
+		Use:   "wait [name]...",
+		Short: "Wait for RHINO jobs to reach a status",
+		Long:  "\nWait for one or more RHINO jobs to reach a status, and exit with an error if any of them fails",
+		Example: `  rhino wait hello
+  rhino wait job1 job2 --for=Completed --timeout 10m --namespace user_space`,
+		Args: waitOpts.argsCheck,
+		RunE: waitOpts.runWait,
+	}
+
+	waitCmd.Flags().StringVar(&waitOpts.forStatus, "for", string(rhinojob.Completed), "the status to wait for: Pending, Running, Completed or Failed")
+	waitCmd.Flags().DurationVar(&waitOpts.timeout, "timeout", 0, "the maximum time to wait, e.g. 30s or 10m. 0 means no timeout")
+	waitOpts.addKubeFlags(waitCmd.Flags())
+
+	return waitCmd
+}
+
+func (w *WaitOptions) argsCheck(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("[name] cannot be empty")
+	}
+	w.rhinojobNames = args
+	switch rhinojob.JobStatus(w.forStatus) {
+	case rhinojob.Pending, rhinojob.Running, rhinojob.Completed, rhinojob.Failed:
+	default:
+		return fmt.Errorf("the status to wait for (--for) must be Pending, Running, Completed or Failed")
+	}
+	if w.timeout < 0 {
+		return fmt.Errorf("the timeout (--timeout) must be greater than or equal to 0")
+	}
+
+	return nil
+}
+
+func (w *WaitOptions) runWait(cmd *cobra.Command, args []string) error {
+	if err := w.complete(cmd); err != nil {
+		return err
+	}
+	dynamicClient, err := w.rhinoJobClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := contextWithTimeout(w.timeout)
+	defer cancel()
+
+	// Wait for the jobs one by one, all of them sharing the same deadline
+	failed := 0
+	for _, name := range w.rhinojobNames {
+		err := waitForRhinoJob(ctx, dynamicClient, w.rhinoJobResource(), w.namespace, name, rhinojob.JobStatus(w.forStatus), nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			failed++
+			continue
+		}
+		fmt.Println("RhinoJob", name, "is", w.forStatus)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d RhinoJobs did not reach status %s", failed, len(w.rhinojobNames), w.forStatus)
+	}
+	return nil
+}
+
+// contextWithTimeout returns a context that expires after the timeout, or never if the timeout is 0
+func contextWithTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// getJobStatus reads the status of a RHINO job from its unstructured form
+func getJobStatus(obj *unstructured.Unstructured) rhinojob.JobStatus {
+	status, _, _ := unstructured.NestedString(obj.Object, "status", "jobStatus")
+	return rhinojob.JobStatus(status)
+}
+
+// jobStatusOrder orders the statuses of a RHINO job as it goes through them, the unknown status coming first
+var jobStatusOrder = map[rhinojob.JobStatus]int{
+	rhinojob.Pending:   1,
+	rhinojob.Running:   2,
+	rhinojob.Completed: 3,
+	rhinojob.Failed:    3,
+}
+
+// waitForRhinoJob watches a RHINO job until it reaches forStatus.
+// It returns an error if the job is already past forStatus or ends in another status, is deleted, or the context expires.
+// onChange is called, if not nil, every time the status of the job changes.
+func waitForRhinoJob(ctx context.Context, client dynamic.Interface, gvr schema.GroupVersionResource,
+	namespace string, name string, forStatus rhinojob.JobStatus, onChange func(rhinojob.JobStatus)) error {
+	rjClient := client.Resource(gvr).Namespace(namespace)
+	var lastStatus rhinojob.JobStatus
+
+	// checkStatus reports a status change and tells whether the wait is over
+	checkStatus := func(obj *unstructured.Unstructured) (bool, error) {
+		status := getJobStatus(obj)
+		if status != lastStatus {
+			lastStatus = status
+			if onChange != nil && status != "" {
+				onChange(status)
+			}
+		}
+		if status == forStatus {
+			return true, nil
+		}
+		// A job never goes back to an earlier status, so the wait is over once it is past forStatus
+		if jobStatusOrder[status] >= jobStatusOrder[forStatus] {
+			return true, fmt.Errorf("RhinoJob %s is %s", name, status)
+		}
+		return false, nil
+	}
+
+	for {
+		obj, err := rjClient.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return waitError(ctx, name, forStatus, err)
+		}
+		if done, err := checkStatus(obj); done {
+			return err
+		}
+
+		// Watch the job from the version just read. The watch is restarted
+		// from a fresh read when the server closes it or reports an error.
+		watcher, err := rjClient.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: obj.GetResourceVersion(),
+		})
+		if err != nil {
+			return waitError(ctx, name, forStatus, err)
+		}
+		done, err := func() (bool, error) {
+			defer watcher.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return true, waitError(ctx, name, forStatus, ctx.Err())
+				case event, ok := <-watcher.ResultChan():
+					if !ok {
+						return false, nil
+					}
+					obj, isUnstructured := event.Object.(*unstructured.Unstructured)
+					if !isUnstructured || obj.GetName() != name {
+						// Error events carry a metav1.Status, so read the job again
+						if event.Type == watch.Error {
+							return false, nil
+						}
+						continue
+					}
+					switch event.Type {
+					case watch.Deleted:
+						return true, fmt.Errorf("RhinoJob %s was deleted before it was %s", name, forStatus)
+					case watch.Added, watch.Modified:
+						if done, err := checkStatus(obj); done {
+							return true, err
+						}
+					}
+				}
+			}
+		}()
+		if done {
+			return err
+		}
+	}
+}
+
+// waitError turns an expired context into a clear timeout error
+func waitError(ctx context.Context, name string, forStatus rhinojob.JobStatus, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out waiting for RhinoJob %s to be %s", name, forStatus)
+	}
+	return err
+}
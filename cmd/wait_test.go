@@ -0,0 +1,110 @@
+/*
+ * Copyright 2023 RHINO Team
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	rhinojob "github.com/OpenRHINO/RHINO-Operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestRhinoJob returns an unstructured RHINO job with the given status
+func newTestRhinoJob(name string, status rhinojob.JobStatus) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("openrhino.org/v1alpha1")
+	obj.SetKind("RhinoJob")
+	obj.SetName(name)
+	obj.SetNamespace(testFuncRunNamespace)
+	if status != "" {
+		unstructured.SetNestedField(obj.Object, string(status), "status", "jobStatus")
+	}
+	return obj
+}
+
+// newTestWaitClient returns a fake client holding the job, whose watches replay the given statuses
+func newTestWaitClient(job *unstructured.Unstructured, statuses ...rhinojob.JobStatus) *dynamicfake.FakeDynamicClient {
+	client := newFakeDynamicClient(job)
+	watcher := watch.NewRaceFreeFake()
+	for _, status := range statuses {
+		watcher.Modify(newTestRhinoJob(job.GetName(), status))
+	}
+	client.PrependWatchReactor("rhinojobs", k8stesting.DefaultWatchReactor(watcher, nil))
+	return client
+}
+
+func TestWaitForCompletedJob(t *testing.T) {
+	client := newTestWaitClient(newTestRhinoJob("test-wait", rhinojob.Pending), rhinojob.Running, rhinojob.Completed)
+	var statuses []rhinojob.JobStatus
+	err := waitForRhinoJob(context.TODO(), client, RhinoJobGVR, testFuncRunNamespace, "test-wait", rhinojob.Completed,
+		func(status rhinojob.JobStatus) { statuses = append(statuses, status) })
+	assert.Equal(t, nil, err, "test wait failed: %s", errorMessage(err))
+	assert.Equal(t, []rhinojob.JobStatus{rhinojob.Pending, rhinojob.Running, rhinojob.Completed}, statuses)
+}
+
+func TestWaitForFailedJob(t *testing.T) {
+	client := newTestWaitClient(newTestRhinoJob("test-wait", rhinojob.Running), rhinojob.Failed)
+	err := waitForRhinoJob(context.TODO(), client, RhinoJobGVR, testFuncRunNamespace, "test-wait", rhinojob.Completed, nil)
+	assert.Equal(t, fmt.Errorf("RhinoJob test-wait is Failed"), err)
+}
+
+func TestWaitForAlreadyCompletedJob(t *testing.T) {
+	client := newFakeDynamicClient(newTestRhinoJob("test-wait", rhinojob.Completed))
+	err := waitForRhinoJob(context.TODO(), client, RhinoJobGVR, testFuncRunNamespace, "test-wait", rhinojob.Completed, nil)
+	assert.Equal(t, nil, err, "test wait failed: %s", errorMessage(err))
+}
+
+func TestWaitForPassedStatus(t *testing.T) {
+	client := newFakeDynamicClient(newTestRhinoJob("test-wait", rhinojob.Running))
+	err := waitForRhinoJob(context.TODO(), client, RhinoJobGVR, testFuncRunNamespace, "test-wait", rhinojob.Pending, nil)
+	assert.Equal(t, fmt.Errorf("RhinoJob test-wait is Running"), err)
+
+	client = newTestWaitClient(newTestRhinoJob("test-wait", ""), rhinojob.Completed)
+	err = waitForRhinoJob(context.TODO(), client, RhinoJobGVR, testFuncRunNamespace, "test-wait", rhinojob.Running, nil)
+	assert.Equal(t, fmt.Errorf("RhinoJob test-wait is Completed"), err)
+}
+
+func TestWaitForDeletedJob(t *testing.T) {
+	job := newTestRhinoJob("test-wait", rhinojob.Running)
+	client := newFakeDynamicClient(job)
+	watcher := watch.NewRaceFreeFake()
+	watcher.Delete(job)
+	client.PrependWatchReactor("rhinojobs", k8stesting.DefaultWatchReactor(watcher, nil))
+	err := waitForRhinoJob(context.TODO(), client, RhinoJobGVR, testFuncRunNamespace, "test-wait", rhinojob.Completed, nil)
+	assert.Equal(t, fmt.Errorf("RhinoJob test-wait was deleted before it was Completed"), err)
+}
+
+func TestWaitTimeout(t *testing.T) {
+	client := newTestWaitClient(newTestRhinoJob("test-wait", rhinojob.Running))
+	ctx, cancel := contextWithTimeout(100 * time.Millisecond)
+	defer cancel()
+	err := waitForRhinoJob(ctx, client, RhinoJobGVR, testFuncRunNamespace, "test-wait", rhinojob.Completed, nil)
+	assert.Equal(t, fmt.Errorf("timed out waiting for RhinoJob test-wait to be Completed"), err)
+}
+
+func TestWaitInvalidStatus(t *testing.T) {
+	rootCmd := NewRootCommand()
+	rootCmd.SetArgs([]string{"wait", "test-wait", "--for", "Done"})
+	err := rootCmd.Execute()
+	assert.Equal(t, fmt.Errorf("the status to wait for (--for) must be Pending, Running, Completed or Failed"), err)
+}
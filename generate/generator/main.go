@@ -19,9 +19,10 @@ import (
 
 //go:generate go run main.go
 const templatesPath = "../../templates/func"
+const operatorManifestsPath = "../../manifests/operator"
 
-// This program generates zz_filesystem_generated.go file containing byte array variable named TemplatesZip.
-// The variable contains zip of "./templates/func" directory.
+// This program generates zz_filesystem_generated.go file containing byte array variables named TemplatesZip
+// and OperatorManifestsZip. The variables contain zips of "./templates/func" and "./manifests/operator" directories.
 func main() {
 	f, err := os.OpenFile("../../generate/zz_filesystem_generated.go", os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -32,19 +33,33 @@ func main() {
 	srcOut := bufio.NewWriter(f)
 	defer srcOut.Flush()
 
-	_, err = fmt.Fprintf(srcOut, "// Code generated by go generate; DO NOT EDIT.\npackage generate\n\nvar TemplatesZip = []byte{")
+	_, err = fmt.Fprintf(srcOut, "// Code generated by go generate; DO NOT EDIT.\npackage generate\n")
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := writeZipVariable(srcOut, "TemplatesZip", templatesPath); err != nil {
+		log.Fatal(err)
+	}
+	if err := writeZipVariable(srcOut, "OperatorManifestsZip", operatorManifestsPath); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeZipVariable writes a byte array variable containing the zip of a directory
+func writeZipVariable(srcOut io.Writer, varName string, dirPath string) error {
+	_, err := fmt.Fprintf(srcOut, "\nvar %s = []byte{", varName)
+	if err != nil {
+		return err
+	}
 
 	zipWriter := zip.NewWriter(newGoByteArrayWriter(srcOut))
 	buff := make([]byte, 4*1024)
-	err = filepath.Walk(templatesPath, func(path string, info fs.FileInfo, err error) error {
+	err = filepath.Walk(dirPath, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		name, err := filepath.Rel(templatesPath, path)
+		name, err := filepath.Rel(dirPath, path)
 		if err != nil {
 			return err
 		}
@@ -104,13 +119,11 @@ func main() {
 	})
 	zipWriter.Close()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	_, err = fmt.Fprint(srcOut, "\n}\n")
-	if err != nil {
-		log.Fatal(err)
-	}
+	return err
 }
 
 // goByteArrayWriter dumps bytes as a Go integer hex literals separated by commas into underlying Writer.
@@ -3,20 +3,26 @@ set -o errexit
 set -o nounset
 set -o pipefail
 
-# Look for executable files named $FUNC_NAME and check uniqueness
-file_path=$(find ./ -type f -name "$FUNC_NAME" -executable)
-if [ "$file_path" ]; then
-    if [ "$(echo "$file_path" | wc -l)" -gt 1 ]; then
-        echo "Found multiple executable files named '$FUNC_NAME'. Please check your Makefile!" >&2
-        exit 1 
+# The executables to package, $FUNC_NAMES if set, or else the single $FUNC_NAME
+func_names="${FUNC_NAMES:-$FUNC_NAME}"
+mkdir -p "/exec"
+
+# Look for the executable files of each name and check uniqueness
+for func_name in $func_names; do
+    file_path=$(find ./ -type f -name "$func_name" -executable)
+    if [ "$file_path" ]; then
+        if [ "$(echo "$file_path" | wc -l)" -gt 1 ]; then
+            echo "Found multiple executable files named '$func_name'. Please check your Makefile!" >&2
+            exit 1
+        fi
+        mv "$file_path" "/exec/$func_name"
+        echo "Loading app $func_name"
+    else
+    # Exit and report an err when no $func_name file is found
+        echo "Cannot find file $func_name!" >&2
+        exit 1
     fi
-    mv "$file_path" "/app/$FUNC_NAME"
-    echo "Loading app $FUNC_NAME"
-else
-# Exit and report an err when no $FUNC_NAME file is found
-    echo "Cannot find file $FUNC_NAME!" >&2
-    exit 1
-fi
+done
 
 if [ ! -d "/shared_lib" ]; then
     mkdir "/shared_lib"
@@ -24,8 +30,10 @@ fi
 cd "/shared_lib"
 echo "The shared_lib dir created"
 
-# Identify which libs need to be loaded
-sharedlibs=$(ldd "/app/$FUNC_NAME" | grep -vE "ld-musl-x86_64|mpi" | awk '{print $3}' || true)
+# Identify which libs need to be loaded by any of the executables
+sharedlibs=$(for func_name in $func_names; do
+    ldd "/exec/$func_name" | grep -vE "ld-musl-x86_64|mpi" | awk '{print $3}' || true
+done | sort -u)
 if [ "$sharedlibs" != "" ]; then
     echo "Shared libs found"
     echo "$sharedlibs" > path.txt